@@ -0,0 +1,45 @@
+package runner
+
+import (
+	"context"
+	"reflect"
+)
+
+// Getter allows produced values to be retrieved by type
+type Getter interface {
+	// Value returns the produced value of valueType, which must be an interface type or a slice
+	// of an interface type.  Lazy producers are called if needed.
+	Value(valueType reflect.Type) (interface{}, error)
+
+	// Group returns the values collected in the group name, see Runner.AddToGroup.  Groups are
+	// kept while Main runs so they can be retrieved from Main.Run.
+	Group(name string) []interface{}
+}
+
+// Accessor is returned by BuildOnly, it allows direct use of built values and tears them down
+// when Close is called
+type Accessor interface {
+	Getter
+
+	// Close closes the built values as described by the Run function and returns any errors
+	Close() []error
+}
+
+// Get returns the produced value of type T from getter
+func Get[T any](getter Getter) (T, error) {
+	var result T
+	value, err := getter.Value(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return result, err
+	}
+	return value.(T), nil
+}
+
+// FromContext returns a Getter for the Runner that provided ctx, or nil if ctx did not come from a
+// Runner.  Producers that depend on context.Context are given such a context so helpers they call
+// can retrieve dependencies without the Runner being passed down.  It must only be used while
+// the Runner is building, before that values may not exist and after it Run releases them.
+func FromContext(ctx context.Context) Getter {
+	getter, _ := ctx.Value(runnerContextKey{}).(Getter)
+	return getter
+}
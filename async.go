@@ -0,0 +1,112 @@
+package runner
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// asyncResult is a value an async producer will send, awaited once nothing else can be built
+type asyncResult struct {
+	producer *producer
+	ch       reflect.Value
+	deadline time.Time
+}
+
+// AddAsync see Runner interface doc
+func (r *runner) AddAsync(timeout time.Duration, producerFunc interface{}) error {
+	itemType, err := r.producerType(producerFunc)
+	if err != nil {
+		return err
+	}
+	types := outTypes(itemType)
+	if len(types) != 1 || types[0].Kind() != reflect.Chan ||
+		types[0].ChanDir() != reflect.RecvDir || types[0].Elem().Kind() != reflect.Interface {
+		return fmt.Errorf("%w type: %v", ErrAsyncInvalid, itemType)
+	}
+	valueTypes := r.provides(types[0].Elem())
+	for _, valueType := range valueTypes {
+		if r.lazy[valueType] != nil {
+			return fmt.Errorf("%w type: %v", ErrLazyConflict, valueType)
+		}
+	}
+	for _, valueType := range valueTypes {
+		r.produceCounts[valueType]++
+	}
+	for _, inType := range dependencies(itemType) {
+		if inType.Kind() == reflect.Slice {
+			r.provideSlice[inType.Elem()] = true
+		}
+	}
+	r.producers = append(r.producers, &producer{
+		value:        reflect.ValueOf(producerFunc),
+		index:        len(r.producers),
+		async:        true,
+		asyncTimeout: timeout,
+	})
+	return nil
+}
+
+// startAsync calls the async producer p with in and records the channel it returned to be
+// awaited later
+func (r *runner) startAsync(p *producer, in []reflect.Value) error {
+	results, err := r.call(p, in)
+	if err != nil {
+		return err
+	}
+	if len(results) == 2 && !results[1].IsNil() {
+		return results[1].Interface().(error)
+	}
+	r.pending = append(r.pending, asyncResult{
+		producer: p,
+		ch:       results[0],
+		deadline: time.Now().Add(p.asyncTimeout),
+	})
+	return nil
+}
+
+// awaitAsync receives the values of all pending async producers, they have been working
+// concurrently since they were called so this waits for the slowest
+func (r *runner) awaitAsync() error {
+	pending := r.pending
+	r.pending = nil
+	for _, v := range pending {
+		err := r.receiveAsync(v)
+		if err != nil {
+			return r.withConsumerChain(v.producer, err)
+		}
+	}
+	return nil
+}
+
+// receiveAsync waits until v's deadline for its value and provides it
+func (r *runner) receiveAsync(v asyncResult) error {
+	timer := time.NewTimer(time.Until(v.deadline))
+	defer timer.Stop()
+	chosen, value, ok := reflect.Select([]reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: v.ch},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(timer.C)},
+	})
+	if chosen == 1 {
+		return fmt.Errorf(
+			"%w func: %v timeout: %v",
+			ErrProducerTimeout,
+			funcName(v.producer.value),
+			v.producer.asyncTimeout,
+		)
+	}
+	if !ok || value.IsNil() {
+		return fmt.Errorf(
+			"%w func: %v type: %v",
+			ErrProducerReturnedNil,
+			funcName(v.producer.value),
+			v.ch.Type().Elem(),
+		)
+	}
+	err := r.countValues(value)
+	if err != nil {
+		return err
+	}
+	r.trackValue(v.producer, value)
+	return r.handleProvidedValue(v.producer, value)
+}
@@ -0,0 +1,6 @@
+//go:build runnerdebug
+
+package runner
+
+// panicOnBug makes internal invariant violations panic, see bug
+const panicOnBug = true
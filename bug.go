@@ -0,0 +1,17 @@
+package runner
+
+import "errors"
+
+// bug reports an internal invariant violation described by message.  It is appended to errs
+// unless SetQuietBugs was used, then it is only logged.  Built with the runnerdebug tag it
+// panics so the violation is caught where it happens.
+func (r *runner) bug(errs []error, message string) []error {
+	if panicOnBug {
+		panic(message)
+	}
+	if r.quietBugs {
+		r.logf("runner: %v", message)
+		return errs
+	}
+	return append(errs, errors.New(message))
+}
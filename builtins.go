@@ -0,0 +1,305 @@
+package runner
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/blbgo/general"
+)
+
+// TypeInfo is provided by the Runner to any producer that depends on it.  It describes the types
+// the producers provided, it is populated once building is complete so it should only be read
+// after that, for example in Main.Run.
+type TypeInfo interface {
+	// Types returns the names of the provided types in the order they were first provided
+	Types() []string
+}
+
+type typeInfo struct {
+	types []string
+}
+
+// CloserRegistry is provided by the Runner to any producer that depends on it.  It lets a
+// producer have resources it creates internally closed along with the produced values.  They are
+// closed in reverse registration order mixed in with the produced values, so a resource
+// registered while a producer runs is closed after the value that producer returns.  It is safe
+// to use while Main runs, from any goroutine, for resources opened lazily during operation.
+// Being created last those are closed first (within their phase, which is zero), before the
+// values that were produced while building.  Anything registered once closing has started is
+// not closed.
+type CloserRegistry interface {
+	// Add registers closer to be closed
+	Add(closer io.Closer)
+	// AddDelay registers delayCloser to be closed
+	AddDelay(delayCloser general.DelayCloser)
+	// AddBarrier registers a point in the close sequence where closing waits until release is
+	// closed (or receives) or timeout passes, whichever is first.  A nil release just waits for
+	// timeout, for example to give a load balancer time to deregister before closing a server.
+	AddBarrier(release <-chan struct{}, timeout time.Duration)
+}
+
+// closeBarrier is in closers for each CloserRegistry.AddBarrier call
+type closeBarrier struct {
+	release <-chan struct{}
+	timeout time.Duration
+}
+
+type closerRegistry struct {
+	runner *runner
+}
+
+// Failures is provided by the Runner to any producer that depends on it.  It reports why best
+// effort producers failed so a consumer can say why an optional dependency is missing.  The
+// failure for a type is known once all its producers have run, so a producer should also depend
+// on the type (as a slice) or only check after building, for example in Main.Run.
+type Failures interface {
+	// Failure returns the error from the first best effort producer of the interface pointed to
+	// by interfacePtr (like (*SomeInterface)(nil)) that failed, or nil if none did
+	Failure(interfacePtr interface{}) error
+	// Errors returns the errors from all the best effort producers that failed so far, in the
+	// order they failed.  After building (for example in Main.Run) it is every non fatal error
+	// the build had so degraded operation can be reported.
+	Errors() []error
+}
+
+type failures struct {
+	runner *runner
+}
+
+// CloserList is provided by the Runner to any producer that depends on it, for components that
+// coordinate shutdown themselves.  Closers accumulate as producers run so only those registered
+// before the call are included, call it after building (for example in Main.Run) to get them all.
+type CloserList interface {
+	// Closers returns the values registered to be closed, each an io.Closer, a
+	// general.DelayCloser or a ConditionalCloser, in the order they will be closed
+	Closers() []interface{}
+}
+
+type closerList struct {
+	runner *runner
+}
+
+// ShutdownNotifier is provided by the Runner to any producer that depends on it, so several
+// components can react to shutdown (flush, log, deregister) without being the Main.  Shutdown
+// is when Main.Run returns, the callbacks are then called before anything is closed.
+type ShutdownNotifier interface {
+	// OnShutdown registers callback to be called with the error Main.Run returned.  Callbacks are
+	// called one at a time in registration order, each is waited for up to the close timeout.
+	OnShutdown(callback func(err error))
+}
+
+type shutdownNotifier struct {
+	runner *runner
+}
+
+// Documentation is provided by the Runner to any producer that depends on it, for example to
+// render what each component of a running application is and does on an admin page.  Values are
+// added as they are produced so it should be read after building, for example in Main.Run.
+type Documentation interface {
+	// Docs returns the Doc of each provided value that is Documented by the name of the type it
+	// was provided as.  The docs of several values provided as one type (in a slice) are joined
+	// with new lines in the order they were provided.
+	Docs() map[string]string
+}
+
+type documentation struct {
+	runner *runner
+}
+
+// ChildRunners is provided by the Runner to any producer that depends on it, so a plugin can
+// build and tear down its own subgraph on top of the values the Runner provides
+type ChildRunners interface {
+	// Child returns a new child Runner, see Runner.Child
+	Child() Runner
+}
+
+type childRunners struct {
+	runner *runner
+}
+
+// nopLogger is the general.Logger provided when no logger is set
+type nopLogger struct{}
+
+// BuildStats is provided by the Runner to any producer that depends on it.  Like TypeInfo it is
+// populated once building is complete so it should only be read after that, for example in
+// Main.Run for a startup report.
+type BuildStats interface {
+	// Producers returns how many producers were added, not counting lazy ones or factories
+	Producers() int
+	// Values returns how many values producers returned, see Runner.ValueCount
+	Values() int
+	// Closers returns how many values were registered to be closed
+	Closers() int
+	// Duration returns how long building took
+	Duration() time.Duration
+}
+
+type buildStats struct {
+	producers int
+	values    int
+	closers   int
+	duration  time.Duration
+}
+
+var typeInfoType = reflect.TypeOf((*TypeInfo)(nil)).Elem()
+var closerRegistryType = reflect.TypeOf((*CloserRegistry)(nil)).Elem()
+var failuresType = reflect.TypeOf((*Failures)(nil)).Elem()
+var closerListType = reflect.TypeOf((*CloserList)(nil)).Elem()
+var loggerType = reflect.TypeOf((*general.Logger)(nil)).Elem()
+var buildStatsType = reflect.TypeOf((*BuildStats)(nil)).Elem()
+var shutdownNotifierType = reflect.TypeOf((*ShutdownNotifier)(nil)).Elem()
+var documentationType = reflect.TypeOf((*Documentation)(nil)).Elem()
+var childRunnersType = reflect.TypeOf((*ChildRunners)(nil)).Elem()
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// builtinTypes are the types provideBuiltins provides
+var builtinTypes = map[reflect.Type]bool{
+	typeInfoType:         true,
+	closerRegistryType:   true,
+	closerListType:       true,
+	failuresType:         true,
+	loggerType:           true,
+	buildStatsType:       true,
+	shutdownNotifierType: true,
+	documentationType:    true,
+	childRunnersType:     true,
+	contextType:          true,
+}
+
+// runnerContextKey is the context key FromContext uses to find the Runner
+type runnerContextKey struct{}
+
+// Types see TypeInfo interface doc
+func (r *typeInfo) Types() []string {
+	return r.types
+}
+
+// Producers see BuildStats interface doc
+func (r *buildStats) Producers() int { return r.producers }
+
+// Values see BuildStats interface doc
+func (r *buildStats) Values() int { return r.values }
+
+// Closers see BuildStats interface doc
+func (r *buildStats) Closers() int { return r.closers }
+
+// Duration see BuildStats interface doc
+func (r *buildStats) Duration() time.Duration { return r.duration }
+
+// Add see CloserRegistry interface doc
+func (r closerRegistry) Add(closer io.Closer) {
+	r.runner.addCloser(closer, 0)
+}
+
+// AddDelay see CloserRegistry interface doc
+func (r closerRegistry) AddDelay(delayCloser general.DelayCloser) {
+	r.runner.addCloser(delayCloser, 0)
+}
+
+// Closers see CloserList interface doc
+func (r closerList) Closers() []interface{} {
+	infos := r.runner.closeOrder()
+	closers := make([]interface{}, 0, len(infos))
+	for _, v := range infos {
+		if _, ok := v.Closer.(*closeBarrier); !ok {
+			closers = append(closers, v.Closer)
+		}
+	}
+	return closers
+}
+
+// AddBarrier see CloserRegistry interface doc
+func (r closerRegistry) AddBarrier(release <-chan struct{}, timeout time.Duration) {
+	r.runner.addCloser(&closeBarrier{release: release, timeout: timeout}, 0)
+}
+
+// OnShutdown see ShutdownNotifier interface doc
+func (r shutdownNotifier) OnShutdown(callback func(err error)) {
+	r.runner.onShutdownLock.Lock()
+	defer r.runner.onShutdownLock.Unlock()
+	r.runner.onShutdown = append(r.runner.onShutdown, callback)
+}
+
+// Docs see Documentation interface doc
+func (r documentation) Docs() map[string]string {
+	docs := make(map[string]string, len(r.runner.docs))
+	for name, doc := range r.runner.docs {
+		docs[name] = doc
+	}
+	return docs
+}
+
+// saveIfDocumented adds the Doc of value to the docs if it is Documented
+func (r *runner) saveIfDocumented(value reflect.Value) {
+	documented, ok := value.Interface().(Documented)
+	if !ok {
+		return
+	}
+	name := value.Type().String()
+	if doc, ok := r.docs[name]; ok {
+		r.docs[name] = doc + "\n" + documented.Doc()
+		return
+	}
+	r.docs[name] = documented.Doc()
+}
+
+// Failure see Failures interface doc
+func (r failures) Failure(interfacePtr interface{}) error {
+	valueType, err := interfaceType(interfacePtr)
+	if err != nil {
+		return err
+	}
+	return r.runner.failedTypes[valueType]
+}
+
+// Errors see Failures interface doc
+func (r failures) Errors() []error {
+	return append([]error(nil), r.runner.failures...)
+}
+
+// Log does nothing
+func (nopLogger) Log(v ...interface{}) error { return nil }
+
+// Logf does nothing
+func (nopLogger) Logf(format string, v ...interface{}) error { return nil }
+
+// provideBuiltins puts the values the Runner itself provides into values, ctx is the context
+// passed to RunContext (or context.Background).  The general.Logger set with SetLogger (or one
+// that discards everything) is provided if no producer provides general.Logger, a child Runner
+// reads it through from its parent.
+func (r *runner) provideBuiltins(ctx context.Context) {
+	r.typeInfo = &typeInfo{}
+	r.values[typeInfoType] = reflect.ValueOf(r.typeInfo).Convert(typeInfoType)
+	r.buildStats = &buildStats{}
+	r.values[buildStatsType] = reflect.ValueOf(r.buildStats).Convert(buildStatsType)
+	r.values[closerRegistryType] = reflect.ValueOf(closerRegistry{runner: r}).
+		Convert(closerRegistryType)
+	r.values[closerListType] = reflect.ValueOf(closerList{runner: r}).Convert(closerListType)
+	r.values[failuresType] = reflect.ValueOf(failures{runner: r}).Convert(failuresType)
+	r.values[shutdownNotifierType] = reflect.ValueOf(shutdownNotifier{runner: r}).
+		Convert(shutdownNotifierType)
+	r.values[documentationType] = reflect.ValueOf(documentation{runner: r}).
+		Convert(documentationType)
+	r.values[childRunnersType] = reflect.ValueOf(childRunners{runner: r}).Convert(childRunnersType)
+	if !r.canProvide(loggerType) && !r.parentProvides(loggerType) {
+		// the logger from SetLogger is provided unless a producer provides one
+		var logger general.Logger = nopLogger{}
+		if r.logger != nil {
+			logger = r.logger
+		}
+		r.values[loggerType] = reflect.ValueOf(&logger).Elem()
+	}
+	runnerCtx := context.WithValue(ctx, runnerContextKey{}, Getter(r))
+	r.values[contextType] = reflect.ValueOf(&runnerCtx).Elem()
+}
+
+// completeBuiltins fills in the values the Runner provides that describe the finished build
+func (r *runner) completeBuiltins() {
+	r.typeInfo.types = r.TypeSnapshot()
+	r.buildStats.producers = len(r.producers)
+	r.buildStats.values = r.valueCount
+	r.buildStats.closers = r.CloserCount()
+	r.buildStats.duration = time.Since(r.buildStart)
+}
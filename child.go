@@ -0,0 +1,24 @@
+package runner
+
+import "reflect"
+
+// Child see Runner interface doc
+func (r *runner) Child() Runner {
+	child := new()
+	child.parent = r
+	child.closeTimeout = r.closeTimeout
+	child.closerTimeout = r.closerTimeout
+	child.logger = r.logger
+	return child
+}
+
+// Child see ChildRunners interface doc
+func (r childRunners) Child() Runner {
+	return r.runner.Child()
+}
+
+// parentProvides reports whether the parent, if this is a child Runner, will provide a value of
+// valueType
+func (r *runner) parentProvides(valueType reflect.Type) bool {
+	return r.parent != nil && r.parent.staticallyProvided(valueType)
+}
@@ -0,0 +1,49 @@
+package clock
+
+import (
+	"time"
+)
+
+// Clock provides the current time and timers.  Components that depend on Clock instead of
+// calling the time package directly can be given a fake in tests, like a ManualClock, by adding a
+// different producer of Clock in place of NewClock.
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+	// Since returns the time elapsed since t
+	Since(t time.Time) time.Duration
+	// After waits for the duration to elapse and then sends the current time on the returned
+	// channel
+	After(d time.Duration) <-chan time.Time
+	// Sleep pauses the current goroutine for at least the duration d
+	Sleep(d time.Duration)
+}
+
+type clock struct{}
+
+// NewClock provides a Clock backed by the time package
+func NewClock() Clock {
+	return clock{}
+}
+
+// **************** implement Clock on clock
+
+// Now see Clock interface doc
+func (r clock) Now() time.Time {
+	return time.Now()
+}
+
+// Since see Clock interface doc
+func (r clock) Since(t time.Time) time.Duration {
+	return time.Since(t)
+}
+
+// After see Clock interface doc
+func (r clock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// Sleep see Clock interface doc
+func (r clock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
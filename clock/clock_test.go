@@ -0,0 +1,85 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/blbgo/testing/assert"
+)
+
+//********************
+func TestClock(t *testing.T) {
+	a := assert.New(t)
+
+	var r Clock = NewClock()
+	before := time.Now()
+	now := r.Now()
+	a.False(now.Before(before))
+	a.True(r.Since(before) >= 0)
+	select {
+	case fired := <-r.After(time.Millisecond):
+		a.False(fired.Before(now))
+	case <-time.After(time.Second):
+		t.Fatal("After did not fire")
+	}
+	start := time.Now()
+	r.Sleep(time.Millisecond)
+	a.True(time.Since(start) >= time.Millisecond)
+}
+
+//********************
+func TestManualClock(t *testing.T) {
+	a := assert.New(t)
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := NewManualClock(start)
+	var _ Clock = r
+	a.Equal(start, r.Now())
+
+	soon := r.After(time.Second)
+	later := r.After(time.Minute)
+	a.Equal(2, r.Waiting())
+	r.Advance(999 * time.Millisecond)
+	select {
+	case <-soon:
+		t.Fatal("After fired before its duration elapsed")
+	default:
+	}
+
+	r.Advance(time.Millisecond)
+	a.Equal(start.Add(time.Second), <-soon)
+	a.Equal(1, r.Waiting())
+	a.Equal(time.Second, r.Since(start))
+
+	r.Advance(time.Hour)
+	a.Equal(start.Add(time.Hour+time.Second), <-later)
+	a.Equal(0, r.Waiting())
+
+	a.Equal(r.Now(), <-r.After(0))
+}
+
+func TestManualClockSleep(t *testing.T) {
+	a := assert.New(t)
+
+	r := NewManualClock(time.Time{})
+	slept := make(chan struct{})
+	go func() {
+		r.Sleep(time.Second)
+		close(slept)
+	}()
+	for r.Waiting() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case <-slept:
+		t.Fatal("Sleep returned before the clock was advanced")
+	default:
+	}
+	r.Advance(time.Second)
+	select {
+	case <-slept:
+	case <-time.After(time.Second):
+		t.Fatal("Sleep did not return once the clock was advanced")
+	}
+	a.Equal(0, r.Waiting())
+}
@@ -0,0 +1,82 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// ManualClock is a Clock for tests whose time only moves when Advance is called.  Channels from
+// After and calls to Sleep wait until the clock has been advanced past their duration.
+type ManualClock struct {
+	lock    sync.Mutex
+	now     time.Time
+	waiters []manualWaiter
+}
+
+type manualWaiter struct {
+	at   time.Time
+	fire chan time.Time
+}
+
+// NewManualClock creates a ManualClock whose current time is now
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+// Advance moves the time forward by d, sending the new time on the After channels (and ending
+// the Sleep calls) whose duration has now elapsed
+func (r *ManualClock) Advance(d time.Duration) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.now = r.now.Add(d)
+	var waiters []manualWaiter
+	for _, waiter := range r.waiters {
+		if waiter.at.After(r.now) {
+			waiters = append(waiters, waiter)
+			continue
+		}
+		waiter.fire <- r.now
+	}
+	r.waiters = waiters
+}
+
+// Waiting returns how many After channels and Sleep calls are still waiting, so a test can
+// advance once the code under test is waiting
+func (r *ManualClock) Waiting() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return len(r.waiters)
+}
+
+// **************** implement Clock on ManualClock
+
+// Now see Clock interface doc
+func (r *ManualClock) Now() time.Time {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.now
+}
+
+// Since see Clock interface doc
+func (r *ManualClock) Since(t time.Time) time.Duration {
+	return r.Now().Sub(t)
+}
+
+// After see Clock interface doc, a duration that is not positive has already elapsed
+func (r *ManualClock) After(d time.Duration) <-chan time.Time {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	// buffered so Advance never blocks on a channel nobody receives from
+	fire := make(chan time.Time, 1)
+	if d <= 0 {
+		fire <- r.now
+		return fire
+	}
+	r.waiters = append(r.waiters, manualWaiter{at: r.now.Add(d), fire: fire})
+	return fire
+}
+
+// Sleep see Clock interface doc
+func (r *ManualClock) Sleep(d time.Duration) {
+	<-r.After(d)
+}
@@ -0,0 +1,342 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/blbgo/general"
+)
+
+// OnClosed see Runner interface doc
+func (r *runner) OnClosed(callback func(errs []error)) {
+	r.onClosed = append(r.onClosed, callback)
+}
+
+// SetCloseSorter see Runner interface doc
+func (r *runner) SetCloseSorter(sorter func(closers []CloserInfo) []CloserInfo) {
+	r.closeSorter = sorter
+}
+
+// CloseGraph see Runner interface doc
+func (r *runner) CloseGraph() string {
+	closers := r.closeOrder()
+	var b strings.Builder
+	b.WriteString("digraph close {\n")
+	for _, v := range closers {
+		fmt.Fprintf(&b, "\tc%d [label=%q];\n", v.Index, fmt.Sprintf("%T", v.Closer))
+	}
+	for i := 1; i < len(closers); i++ {
+		fmt.Fprintf(&b, "\tc%d -> c%d;\n", closers[i-1].Index, closers[i].Index)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// closeOrder returns the closers in the order they are to be closed, by descending phase and
+// reverse creation order within a phase, unless a close sorter was set
+func (r *runner) closeOrder() []CloserInfo {
+	closers := r.closerList()
+	infos := make([]CloserInfo, len(closers))
+	for i := range closers {
+		infos[i] = closers[len(closers)-1-i]
+	}
+	sort.SliceStable(infos, func(i, j int) bool { return infos[i].Phase > infos[j].Phase })
+	if r.closeSorter == nil {
+		return infos
+	}
+	return r.closeSorter(infos)
+}
+
+func (r *runner) saveIfDrainer(value reflect.Value) {
+	drainer, ok := value.Interface().(Drainer)
+	if ok {
+		r.drainers = append(r.drainers, drainer)
+	}
+}
+
+func (r *runner) saveIfGuard(value reflect.Value) {
+	guard, ok := value.Interface().(ShutdownGuard)
+	if ok {
+		r.guards = append(r.guards, guard)
+	}
+}
+
+// defaultGuardRecheck is how long to wait before asking a ShutdownGuard again if it did not
+// suggest a duration
+const defaultGuardRecheck = 100 * time.Millisecond
+
+// waitForGuards waits until every ShutdownGuard can shutdown, for up to the shutdown grace or
+// if none is set the close timeout
+func (r *runner) waitForGuards(errs []error) []error {
+	if len(r.guards) == 0 {
+		return errs
+	}
+	allowed := r.shutdownGrace
+	if allowed <= 0 {
+		allowed = r.closeTimeout
+	}
+	deadline := time.Now().Add(allowed)
+	for _, guard := range r.guards {
+		for {
+			ok, wait := guard.CanShutdown()
+			if ok {
+				break
+			}
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				errs = append(errs, fmt.Errorf("%w type: %T", ErrShutdownGuardTimeout, guard))
+				break
+			}
+			if wait <= 0 {
+				wait = defaultGuardRecheck
+			}
+			if wait > remaining {
+				wait = remaining
+			}
+			time.Sleep(wait)
+		}
+	}
+	return errs
+}
+
+// drain calls Drain on the drainers in reverse creation order with ctx, the close budget
+func (r *runner) drain(ctx context.Context, errs []error) []error {
+	for i := len(r.drainers) - 1; i >= 0; i-- {
+		err := r.drainers[i].Drain(ctx)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func (r *runner) saveIfCloser(value reflect.Value, phase int) {
+	valueInterface := value.Interface()
+	if r.closeExcluded[value.Type()] || r.closeExcluded[reflect.TypeOf(valueInterface)] {
+		return
+	}
+	switch valueInterface.(type) {
+	case io.Closer, general.DelayCloser, ConditionalCloser:
+		r.addCloser(valueInterface, phase)
+	}
+}
+
+// addCloser adds closer to closers, it is locked as factories may create closers while Main
+// runs
+func (r *runner) addCloser(closer interface{}, phase int) {
+	r.closersLock.Lock()
+	defer r.closersLock.Unlock()
+	r.closers = append(r.closers, CloserInfo{Closer: closer, Index: len(r.closers), Phase: phase})
+}
+
+// closerList returns a copy of closers
+func (r *runner) closerList() []CloserInfo {
+	r.closersLock.Lock()
+	defer r.closersLock.Unlock()
+	return append([]CloserInfo(nil), r.closers...)
+}
+
+// ExcludeFromClose see Runner interface doc
+func (r *runner) ExcludeFromClose(typePtr interface{}) error {
+	ptrType := reflect.TypeOf(typePtr)
+	if ptrType == nil {
+		return ErrExcludeInvalid
+	}
+	if ptrType.Kind() == reflect.Ptr && ptrType.Elem().Kind() == reflect.Interface {
+		ptrType = ptrType.Elem()
+	}
+	r.closeExcluded[ptrType] = true
+	return nil
+}
+
+// saveIfClosers saves value (or each element if it is a slice) if it is a closer, nils are
+// skipped as they may be returned along with an error
+func (r *runner) saveIfClosers(value reflect.Value, phase int) {
+	if value.Kind() != reflect.Slice {
+		if !isNil(value) {
+			r.saveIfCloser(value, phase)
+		}
+		return
+	}
+	for i := 0; i < value.Len(); i++ {
+		r.saveIfClosers(value.Index(i), phase)
+	}
+}
+
+// closeContext closes any values in the runner that implement the io.Closer,
+// general.DelayCloser or ConditionalCloser interfaces.  They are closed in reverse creation
+// order.  This will insure a values close will be called before any of its dependencies.  The
+// budget for draining and closing is from closeBudget, the time remaining before the deadline of
+// the close context or ctx, otherwise closeTimeout.  Drainers are all drained before anything is
+// closed.  Once done the shutdown hooks are run, the errors are transformed and the OnClosed
+// callbacks are called.
+func (r *runner) closeContext(ctx context.Context, errs []error) []error {
+	r.timings.CloseStarted = time.Now()
+	errs = r.closeValues(ctx, errs)
+	errs = r.runShutdownHooks(errs)
+	r.timings.CloseCompleted = time.Now()
+	errs = r.transformErrors(errs)
+	for _, callback := range r.onClosed {
+		callback(errs)
+	}
+	return errs
+}
+
+// AddShutdownHook see Runner interface doc
+func (r *runner) AddShutdownHook(hook func() error) {
+	r.shutdownHooks = append(r.shutdownHooks, hook)
+}
+
+// runShutdownHooks runs the AddShutdownHook hooks in reverse order, adding their errors to errs
+func (r *runner) runShutdownHooks(errs []error) []error {
+	for i := len(r.shutdownHooks) - 1; i >= 0; i-- {
+		if err := r.shutdownHooks[i](); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// SetCloserTimeout see Runner interface doc
+func (r *runner) SetCloserTimeout(timeout time.Duration) {
+	r.closerTimeout = timeout
+}
+
+// transformErrors applies the SetErrorTransform transform to each of errs, dropping those it
+// returns nil for
+func (r *runner) transformErrors(errs []error) []error {
+	if r.errorTransform == nil {
+		return errs
+	}
+	var transformed []error
+	for _, err := range errs {
+		if err = r.errorTransform(err); err != nil {
+			transformed = append(transformed, err)
+		}
+	}
+	return transformed
+}
+
+// closeValues drains and closes the values for closeContext
+func (r *runner) closeValues(ctx context.Context, errs []error) []error {
+	// the outcome ConditionalClosers are told is before any errors from closing
+	success := len(errs) == 0
+	// the budget ends at its deadline or once the close context is done
+	parent := context.Background()
+	if r.closeCtx != nil {
+		parent = r.closeCtx
+	}
+	budgetCtx, cancel := context.WithTimeout(parent, r.closeBudget(ctx))
+	defer cancel()
+	errs = r.drain(budgetCtx, errs)
+
+	closers := r.closeOrder()
+	doneChan := make(chan error)
+	budget := budgetCtx.Done()
+	for _, info := range closers {
+		var ok bool
+		switch v := info.Closer.(type) {
+		case io.Closer:
+			if errs, ok = r.closeBounded(v, v.Close, budget, errs); !ok {
+				return errs
+			}
+		case ConditionalCloser:
+			closeFunc := func() error { return v.Close(success) }
+			if errs, ok = r.closeBounded(v, closeFunc, budget, errs); !ok {
+				return errs
+			}
+		case general.DelayCloser:
+			v.Close(doneChan)
+			select {
+			case err, ok := <-doneChan:
+				if !ok {
+					return r.bug(errs, "BUG runner DelayCloser doneChan closed")
+				}
+				if err != nil {
+					errs = append(errs, err)
+				}
+			case <-budget:
+				r.logf("runner: close budget ran out while closing %T", v)
+				return append(errs, ErrDelayCloserTimeout)
+			}
+		case *closeBarrier:
+			waitForBarrier(v, budget)
+		default:
+			errs = r.bug(errs, "BUG runner has non closer in closers")
+		}
+	}
+	return errs
+}
+
+// closeBounded calls closeFunc, the Close of closer, limited by the closer timeout and budget
+// (done once the close budget ran out).  It reports whether closing can go on, it can not once
+// the budget ran out.
+func (r *runner) closeBounded(
+	closer interface{},
+	closeFunc func() error,
+	budget <-chan struct{},
+	errs []error,
+) ([]error, bool) {
+	// called in a goroutine so a Close that blocks can not hang the close sequence
+	closed := make(chan error, 1)
+	go func() { closed <- closeFunc() }()
+	var closerTimeout <-chan time.Time
+	if r.closerTimeout > 0 {
+		closerTimeout = time.After(r.closerTimeout)
+	}
+	select {
+	case err := <-closed:
+		if err != nil {
+			errs = append(errs, err)
+		}
+	case <-closerTimeout:
+		r.logf("runner: closer %T did not return within %v", closer, r.closerTimeout)
+		errs = append(errs, fmt.Errorf("%w closer: %T", ErrCloserTimeout, closer))
+	case <-budget:
+		r.logf("runner: close budget ran out while closing %T", closer)
+		return append(errs, fmt.Errorf("%w closer: %T", ErrCloseTimeout, closer)), false
+	}
+	return errs, true
+}
+
+// waitForBarrier waits for barrier to be released or time out, but not past the close budget
+func waitForBarrier(barrier *closeBarrier, budget <-chan struct{}) {
+	timer := time.NewTimer(barrier.timeout)
+	defer timer.Stop()
+	select {
+	case <-barrier.release:
+	case <-timer.C:
+	case <-budget:
+	}
+}
+
+// SetCloseContext see Runner interface doc
+func (r *runner) SetCloseContext(ctx context.Context) {
+	r.closeCtx = ctx
+}
+
+// closeBudget returns how long the close sequence may wait for general.DelayCloser complete
+// notifications, it also stops once the close context is done.  The close context's deadline is
+// the budget, or failing that the deadline of ctx (the run context) unless that already passed,
+// as it is then what ended the run.
+func (r *runner) closeBudget(ctx context.Context) time.Duration {
+	if r.closeCtx != nil {
+		ctx = r.closeCtx
+	} else if ctx.Err() != nil {
+		return r.closeTimeout
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return r.closeTimeout
+	}
+	budget := time.Until(deadline)
+	if budget < 0 {
+		return 0
+	}
+	return budget
+}
@@ -0,0 +1,60 @@
+package runner
+
+import "reflect"
+
+// CollectByInterface see Runner interface doc
+func (r *runner) CollectByInterface(interfacePtr interface{}) error {
+	valueType, err := interfaceType(interfacePtr)
+	if err != nil {
+		return err
+	}
+	r.collect[valueType] = true
+	return nil
+}
+
+// saveIfCollected adds value to the collection of each interface registered with
+// CollectByInterface that it implements
+func (r *runner) saveIfCollected(value reflect.Value) {
+	concrete := reflect.ValueOf(value.Interface())
+	for valueType := range r.collect {
+		if !concrete.Type().Implements(valueType) {
+			continue
+		}
+		sliceType := reflect.SliceOf(valueType)
+		collected, ok := r.collected[sliceType]
+		if !ok {
+			collected = reflect.MakeSlice(sliceType, 0, 1)
+		}
+		element := reflect.New(valueType).Elem()
+		element.Set(concrete)
+		r.collected[sliceType] = reflect.Append(collected, element)
+	}
+}
+
+// findCollected returns the collection for sliceType, a slice of an interface registered with
+// CollectByInterface.  Until nothing else can be built it is a waitError so every value that
+// will be provided is scanned first.
+func (r *runner) findCollected(sliceType reflect.Type) (reflect.Value, error) {
+	if !r.collectDone {
+		return nilValue, &waitError{paramType: sliceType, waitType: sliceType}
+	}
+	collected, ok := r.collected[sliceType]
+	if !ok {
+		return reflect.MakeSlice(sliceType, 0, 0), nil
+	}
+	return collected, nil
+}
+
+// finishCollecting wakes the producers waiting for collections, it is called once nothing else
+// can be built.  It reports whether any were woken.
+func (r *runner) finishCollecting() bool {
+	r.collectDone = true
+	for valueType := range r.collect {
+		sliceType := reflect.SliceOf(valueType)
+		if producers, ok := r.waiting[sliceType]; ok {
+			delete(r.waiting, sliceType)
+			r.woken = append(r.woken, producers...)
+		}
+	}
+	return len(r.woken) > 0
+}
@@ -0,0 +1,103 @@
+package runner
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// consumerBinding is a producer from BindFor and, once it has been called, its value
+type consumerBinding struct {
+	producer *producer
+	value    reflect.Value
+}
+
+// BindFor see Runner interface doc
+func (r *runner) BindFor(
+	consumerPtr interface{},
+	interfacePtr interface{},
+	producerFunc interface{},
+) error {
+	consumerType, err := interfaceType(consumerPtr)
+	if err != nil {
+		return err
+	}
+	boundType, err := interfaceType(interfacePtr)
+	if err != nil {
+		return err
+	}
+	itemType, err := r.producerType(producerFunc)
+	if err != nil {
+		return err
+	}
+	types := outTypes(itemType)
+	if len(types) != 1 || types[0] != boundType {
+		return fmt.Errorf("%w type: %v producer: %v", ErrBindForInvalid, boundType, itemType)
+	}
+	if r.consumerBindings[consumerType] == nil {
+		r.consumerBindings[consumerType] = make(map[reflect.Type]*consumerBinding)
+	}
+	if r.consumerBindings[consumerType][boundType] != nil {
+		return fmt.Errorf("%w consumer: %v type: %v", ErrBindConflict, consumerType, boundType)
+	}
+	r.consumerBindings[consumerType][boundType] = &consumerBinding{
+		producer: &producer{value: reflect.ValueOf(producerFunc), lazy: true},
+	}
+	return nil
+}
+
+// boundFor reports whether a BindFor binding gives the producer p its dependency of valueType
+func (r *runner) boundFor(p *producer, valueType reflect.Type) bool {
+	if len(r.consumerBindings) == 0 {
+		return false
+	}
+	for _, consumerType := range r.providedTypes(p.value.Type()) {
+		if r.consumerBindings[consumerType][valueType] != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// findParamFor is findParam for a dependency of the producer p, a BindFor binding for one of
+// the types p provides is used first
+func (r *runner) findParamFor(p *producer, paramType reflect.Type) (reflect.Value, error) {
+	if len(r.consumerBindings) > 0 {
+		for _, consumerType := range r.providedTypes(p.value.Type()) {
+			if binding := r.consumerBindings[consumerType][paramType]; binding != nil {
+				return r.resolveConsumerBinding(binding)
+			}
+		}
+	}
+	return r.findParam(paramType)
+}
+
+// resolveConsumerBinding returns the value of binding, calling its producer the first time
+func (r *runner) resolveConsumerBinding(binding *consumerBinding) (reflect.Value, error) {
+	if binding.value.IsValid() {
+		return binding.value, nil
+	}
+	p := binding.producer
+	in, err := r.inputs(p)
+	if err != nil {
+		return nilValue, err
+	}
+	results, err := r.call(p, in)
+	if err != nil {
+		return nilValue, err
+	}
+	if len(results) == 2 && !results[1].IsNil() {
+		r.saveIfClosers(results[0], p.phase)
+		return nilValue, results[1].Interface().(error)
+	}
+	if isNil(results[0]) {
+		return nilValue, fmt.Errorf(
+			"%w func: %v type: %v",
+			ErrProducerReturnedNil,
+			funcName(p.value),
+			results[0].Type(),
+		)
+	}
+	r.trackValue(p, results[0])
+	binding.value = results[0]
+	return binding.value, nil
+}
@@ -0,0 +1,84 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// chainLink is how a producer was reached while searching for the producer of Main, the
+// producer depends on valueType which from provides
+type chainLink struct {
+	from      *producer
+	valueType reflect.Type
+}
+
+// withConsumerChain wraps err, a missing dependency of p, with the chain of types from Main to
+// the missing one like "Main needs Server needs Router needs Store".  err is returned as is if
+// it is not about a missing dependency or Main does not transitively need p.
+func (r *runner) withConsumerChain(p *producer, err error) error {
+	var missingType reflect.Type
+	var waitErr *waitError
+	var noProducerErr *noProducerError
+	switch {
+	case errors.As(err, &waitErr):
+		missingType = waitErr.paramType
+	case errors.As(err, &noProducerErr):
+		missingType = noProducerErr.paramType
+	default:
+		return err
+	}
+	chain := r.consumerChain(p)
+	if chain == nil {
+		return err
+	}
+	names := make([]string, 0, len(chain)+1)
+	for _, v := range chain {
+		names = append(names, v.String())
+	}
+	names = append(names, missingType.String())
+	return fmt.Errorf("%w chain: %v", err, strings.Join(names, " needs "))
+}
+
+// consumerChain searches the consumers of the types p provides, and their consumers, for the
+// producer of Main.  It returns the types from Main to the one p provides that leads to Main,
+// nil if none does.
+func (r *runner) consumerChain(p *producer) []reflect.Type {
+	links := map[*producer]chainLink{p: {}}
+	queue := []*producer{p}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		types := r.providedTypes(current.value.Type())
+		if containsType(types, mainType) {
+			chain := []reflect.Type{mainType}
+			for current != p {
+				link := links[current]
+				chain = append(chain, link.valueType)
+				current = link.from
+			}
+			return chain
+		}
+		for _, valueType := range types {
+			for _, consumer := range r.producers {
+				if _, ok := links[consumer]; ok || !dependsOn(consumer, valueType) {
+					continue
+				}
+				links[consumer] = chainLink{from: current, valueType: valueType}
+				queue = append(queue, consumer)
+			}
+		}
+	}
+	return nil
+}
+
+// dependsOn reports whether p depends on valueType, or a slice of it
+func dependsOn(p *producer, valueType reflect.Type) bool {
+	for _, inType := range dependencies(p.value.Type()) {
+		if inType == valueType || (inType.Kind() == reflect.Slice && inType.Elem() == valueType) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+// Package envconfig fills config structs from environment variables so a producer can provide
+// config read from the environment to the producers that consume it
+package envconfig
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/blbgo/runner"
+)
+
+// ErrNotStructPointer indicates Load was not given a pointer to a struct
+var ErrNotStructPointer = errors.New("config must be a pointer to a struct")
+
+// ErrRequired indicates a field tagged required has no environment variable set and no default,
+// it will be wrapped so the variable name can be included
+var ErrRequired = errors.New("required environment variable not set")
+
+// ErrInvalidValue indicates an environment variable (or default) could not be parsed into its
+// field, it will be wrapped so the variable name and value can be included
+var ErrInvalidValue = errors.New("invalid environment variable value")
+
+// ErrUnsupportedField indicates a field tagged env has a type Load can not set, it will be
+// wrapped so the field can be included
+var ErrUnsupportedField = errors.New("unsupported config field type")
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Load fills the fields of the struct config points to from environment variables.  Only
+// exported fields with an env tag, naming the variable, are set.  A default tag gives the value
+// to use when the variable is not set and a required:"true" tag makes it an error for neither to
+// be set.  Fields may be strings, bools, ints, uints, floats or time.Duration.
+//
+//	type Config struct {
+//		Addr    string        `env:"ADDR" default:":8080"`
+//		DBURL   string        `env:"DB_URL" required:"true"`
+//		Timeout time.Duration `env:"TIMEOUT" default:"5s"`
+//	}
+func Load(config interface{}) error {
+	value := reflect.ValueOf(config)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return ErrNotStructPointer
+	}
+	value = value.Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		name, ok := field.Tag.Lookup("env")
+		if !ok || field.PkgPath != "" {
+			continue
+		}
+		text, ok := os.LookupEnv(name)
+		if !ok {
+			text, ok = field.Tag.Lookup("default")
+		}
+		if !ok {
+			if field.Tag.Get("required") == "true" {
+				return fmt.Errorf("%w name: %v", ErrRequired, name)
+			}
+			continue
+		}
+		err := setField(value.Field(i), text)
+		if errors.Is(err, ErrUnsupportedField) {
+			return fmt.Errorf("%w field: %v", err, field.Name)
+		}
+		if err != nil {
+			return fmt.Errorf("%w name: %v value: %q: %v", ErrInvalidValue, name, text, err)
+		}
+	}
+	return nil
+}
+
+// Provide adds a producer to r that loads a T, a config struct, with Load and provides it as
+// runner.Holder[T] (see runner.ProvideHolder) to the producers that consume the config.  An error
+// from Load fails building.
+//
+//	err := envconfig.Provide[Config](r)
+//	...
+//	func NewServer(config runner.Holder[Config]) (Server, error)
+func Provide[T any](r runner.Runner) error {
+	return runner.ProvideHolder[T](r, func() (T, error) {
+		var config T
+		err := Load(&config)
+		return config, err
+	})
+}
+
+// setField parses text into field
+func setField(field reflect.Value, text string) error {
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(text)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(text)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(text, 0, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(text, 0, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(text, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Errorf("%w type: %v", ErrUnsupportedField, field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package envconfig
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/blbgo/runner"
+	"github.com/blbgo/testing/assert"
+)
+
+//********************
+type testConfig struct {
+	Addr    string        `env:"TEST_ADDR" default:":8080"`
+	Debug   bool          `env:"TEST_DEBUG"`
+	Workers int           `env:"TEST_WORKERS" default:"4"`
+	Limit   uint16        `env:"TEST_LIMIT"`
+	Ratio   float64       `env:"TEST_RATIO" default:"0.5"`
+	Timeout time.Duration `env:"TEST_TIMEOUT" default:"5s"`
+	Ignored string
+	hidden  string `env:"TEST_HIDDEN"`
+}
+
+func TestLoad(t *testing.T) {
+	a := assert.New(t)
+
+	t.Setenv("TEST_DEBUG", "true")
+	t.Setenv("TEST_WORKERS", "0x10")
+	t.Setenv("TEST_LIMIT", "300")
+	t.Setenv("TEST_HIDDEN", "set")
+	config := testConfig{Ignored: "kept"}
+	a.NoError(Load(&config))
+	a.Equal(":8080", config.Addr)
+	a.True(config.Debug)
+	a.Equal(16, config.Workers)
+	a.Equal(uint16(300), config.Limit)
+	a.Equal(0.5, config.Ratio)
+	a.Equal(5*time.Second, config.Timeout)
+	a.Equal("kept", config.Ignored)
+	a.Equal("", config.hidden)
+
+	t.Setenv("TEST_ADDR", ":9090")
+	a.NoError(Load(&config))
+	a.Equal(":9090", config.Addr)
+}
+
+//********************
+func TestLoadRequired(t *testing.T) {
+	a := assert.New(t)
+
+	var config struct {
+		DBURL string `env:"TEST_DB_URL" required:"true"`
+	}
+	err := Load(&config)
+	a.True(errors.Is(err, ErrRequired), err)
+	a.ErrorContains(err, "TEST_DB_URL")
+
+	t.Setenv("TEST_DB_URL", "postgres://db")
+	a.NoError(Load(&config))
+	a.Equal("postgres://db", config.DBURL)
+}
+
+//********************
+func TestLoadInvalidValue(t *testing.T) {
+	a := assert.New(t)
+
+	var config testConfig
+	t.Setenv("TEST_WORKERS", "many")
+	err := Load(&config)
+	a.True(errors.Is(err, ErrInvalidValue), err)
+	a.ErrorContains(err, "TEST_WORKERS")
+	a.ErrorContains(err, "many")
+
+	t.Setenv("TEST_WORKERS", "1")
+	t.Setenv("TEST_LIMIT", "70000")
+	a.True(errors.Is(Load(&config), ErrInvalidValue))
+
+	t.Setenv("TEST_LIMIT", "1")
+	t.Setenv("TEST_TIMEOUT", "soon")
+	a.True(errors.Is(Load(&config), ErrInvalidValue))
+}
+
+//********************
+func TestLoadUnsupported(t *testing.T) {
+	a := assert.New(t)
+
+	var config struct {
+		Hosts []string `env:"TEST_HOSTS" default:"a,b"`
+	}
+	err := Load(&config)
+	a.True(errors.Is(err, ErrUnsupportedField), err)
+	a.ErrorContains(err, "Hosts")
+
+	a.True(errors.Is(Load(config), ErrNotStructPointer))
+	a.True(errors.Is(Load(nil), ErrNotStructPointer))
+	var count int
+	a.True(errors.Is(Load(&count), ErrNotStructPointer))
+}
+
+//********************
+type testMain struct{}
+
+func (r testMain) Run() error { return nil }
+
+func TestProvide(t *testing.T) {
+	a := assert.New(t)
+
+	t.Setenv("TEST_ADDR", ":9090")
+	var got testConfig
+	r := runner.New()
+	a.NoError(Provide[testConfig](r))
+	a.NoError(r.Add(func(config runner.Holder[testConfig]) runner.Main {
+		got = config.Get()
+		return testMain{}
+	}))
+	errs := r.Run()
+	a.Equal(0, len(errs), errs)
+	a.Equal(":9090", got.Addr)
+	a.Equal(4, got.Workers)
+
+	type requiredConfig struct {
+		DBURL string `env:"TEST_DB_URL" required:"true"`
+	}
+	r = runner.New()
+	a.NoError(Provide[requiredConfig](r))
+	a.NoError(r.Add(func(config runner.Holder[requiredConfig]) runner.Main { return testMain{} }))
+	errs = r.Run()
+	a.Equal(1, len(errs), errs)
+	a.True(errors.Is(errs[0], ErrRequired), errs)
+}
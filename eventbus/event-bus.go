@@ -0,0 +1,115 @@
+// Package eventbus provides a publish/subscribe event bus that can be produced and injected into
+// the producers that need loosely coupled communication, closing it drains in-flight events
+package eventbus
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+
+	"github.com/blbgo/general"
+)
+
+// ErrClosed indicates Publish was called after the EventBus was closed
+var ErrClosed = errors.New("event bus closed")
+
+// EventBus delivers published events to the handlers subscribed to their topic.  Each handler
+// gets events in the order they were published, in a goroutine of its own so a slow handler only
+// delays its own events.  Closing it stops new events from being published and waits for those
+// in flight to be delivered, the runner waits for that up to its close timeout.
+type EventBus interface {
+	general.DelayCloser
+	// Subscribe registers handler to be called with each event published to topic
+	Subscribe(topic string, handler func(event interface{}))
+	// Publish delivers event to the handlers subscribed to topic, it does not wait for them.  It
+	// returns ErrClosed, and delivers nothing, once Close has been called.
+	Publish(topic string, event interface{}) error
+}
+
+type eventBus struct {
+	lock        sync.RWMutex
+	subscribers map[string][]*subscriber
+	closed      bool
+	inFlight    sync.WaitGroup
+}
+
+// subscriber is a handler and the events waiting to be delivered to it
+type subscriber struct {
+	handler func(event interface{})
+	lock    sync.Mutex
+	queue   []interface{}
+	// delivering is true while a goroutine is delivering the queue
+	delivering bool
+}
+
+// NewEventBus creates an EventBus with no subscribers
+func NewEventBus() EventBus {
+	return &eventBus{subscribers: make(map[string][]*subscriber)}
+}
+
+// TypeTopic returns a topic named for the type of event, so events can be published and
+// subscribed to by type, like bus.Publish(eventbus.TypeTopic(UserCreated{}), event)
+func TypeTopic(event interface{}) string {
+	return reflect.TypeOf(event).String()
+}
+
+// Subscribe see EventBus interface doc
+func (r *eventBus) Subscribe(topic string, handler func(event interface{})) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.subscribers[topic] = append(r.subscribers[topic], &subscriber{handler: handler})
+}
+
+// Publish see EventBus interface doc
+func (r *eventBus) Publish(topic string, event interface{}) error {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if r.closed {
+		return ErrClosed
+	}
+	for _, s := range r.subscribers[topic] {
+		// added while locked so Close can not start waiting before it is counted
+		r.inFlight.Add(1)
+		r.enqueue(s, event)
+	}
+	return nil
+}
+
+// enqueue adds event to the queue of s, starting a goroutine to deliver it if none is
+func (r *eventBus) enqueue(s *subscriber, event interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.queue = append(s.queue, event)
+	if !s.delivering {
+		s.delivering = true
+		go r.deliver(s)
+	}
+}
+
+// deliver calls the handler of s with each queued event in order until the queue is empty
+func (r *eventBus) deliver(s *subscriber) {
+	for {
+		s.lock.Lock()
+		if len(s.queue) == 0 {
+			s.delivering = false
+			s.lock.Unlock()
+			return
+		}
+		event := s.queue[0]
+		s.queue = s.queue[1:]
+		s.lock.Unlock()
+		s.handler(event)
+		r.inFlight.Done()
+	}
+}
+
+// Close see general.DelayCloser interface doc
+func (r *eventBus) Close(doneChan chan<- error) {
+	r.lock.Lock()
+	r.closed = true
+	r.lock.Unlock()
+	go func() {
+		r.inFlight.Wait()
+		doneChan <- nil
+	}()
+}
@@ -0,0 +1,95 @@
+package eventbus
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blbgo/testing/assert"
+)
+
+//********************
+func TestDeliveryOrder(t *testing.T) {
+	a := assert.New(t)
+
+	bus := NewEventBus()
+	var lock sync.Mutex
+	got := map[string][]int{}
+	for _, name := range []string{"first", "second"} {
+		name := name
+		bus.Subscribe("topic", func(event interface{}) {
+			lock.Lock()
+			defer lock.Unlock()
+			got[name] = append(got[name], event.(int))
+		})
+	}
+	bus.Subscribe("other", func(event interface{}) { t.Error("delivered to other topic") })
+	for i := 0; i < 100; i++ {
+		a.NoError(bus.Publish("topic", i))
+	}
+	closeBus(t, bus)
+	for _, name := range []string{"first", "second"} {
+		a.Equal(100, len(got[name]), name)
+		for i, event := range got[name] {
+			a.Equal(i, event, name)
+		}
+	}
+}
+
+//********************
+func TestPublishAfterClose(t *testing.T) {
+	a := assert.New(t)
+
+	bus := NewEventBus()
+	delivered := false
+	bus.Subscribe(TypeTopic(1), func(event interface{}) { delivered = true })
+	closeBus(t, bus)
+	a.True(errors.Is(bus.Publish(TypeTopic(1), 1), ErrClosed))
+	a.False(delivered)
+}
+
+//********************
+func TestCloseWaitsForHandlers(t *testing.T) {
+	a := assert.New(t)
+
+	bus := NewEventBus()
+	release := make(chan struct{})
+	var handled []interface{}
+	bus.Subscribe("topic", func(event interface{}) {
+		<-release
+		handled = append(handled, event)
+	})
+	a.NoError(bus.Publish("topic", "one"))
+	a.NoError(bus.Publish("topic", "two"))
+
+	doneChan := make(chan error, 1)
+	bus.Close(doneChan)
+	select {
+	case <-doneChan:
+		t.Fatal("Close done while a handler was running")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case err := <-doneChan:
+		a.NoError(err)
+	case <-time.After(time.Second):
+		t.Fatal("Close not done after handlers returned")
+	}
+	a.Equal(2, len(handled))
+}
+
+// closeBus closes bus and waits for it to be done
+func closeBus(t *testing.T, bus EventBus) {
+	doneChan := make(chan error, 1)
+	bus.Close(doneChan)
+	select {
+	case err := <-doneChan:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close not done")
+	}
+}
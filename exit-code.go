@@ -0,0 +1,107 @@
+package runner
+
+import (
+	"errors"
+	"sync"
+)
+
+// Exit codes returned by ExitCode for errors that have not been registered with RegisterExitCode
+const (
+	// ExitCodeOK is returned when there are no errors
+	ExitCodeOK = 0
+	// ExitCodeError is returned for errors from Main.Run, producers, and closers
+	ExitCodeError = 1
+	// ExitCodeWiring is returned when producers could not be added or resolved
+	ExitCodeWiring = 2
+	// ExitCodeSignal is returned when shutdown was caused by a signal (128 + SIGINT)
+	ExitCodeSignal = 130
+)
+
+// ErrShutdownSignal indicates shutdown was caused by an operating system signal.  Errors from
+// signal handling helpers such as signalinterrupt match it with errors.Is.
+var ErrShutdownSignal = errors.New("shutdown signal received")
+
+type exitCode struct {
+	err  error
+	code int
+}
+
+var exitCodesLock sync.Mutex
+var exitCodes []exitCode
+
+// wiringErrors are the errors that indicate producers could not be added or resolved
+var wiringErrors = []error{
+	ErrProducerNil,
+	ErrProducerNotFunc,
+	ErrProducerInvalidReturns,
+	ErrProducerInvalidInputs,
+	ErrProducerVariadicInvalid,
+	ErrFactoryInvalid,
+	ErrAsyncInvalid,
+	ErrFactoryConflict,
+	ErrBindNotImplemented,
+	ErrBindConflict,
+	ErrBindForInvalid,
+	ErrSeedInvalid,
+	ErrSeedConflict,
+	ErrRegistrationInvalid,
+	ErrReplaceAfterRun,
+	ErrReplaceMismatch,
+	ErrPrimaryConflict,
+	ErrStructInvalid,
+	ErrHolderInvalid,
+	ErrGroupType,
+	ErrKeyedInvalid,
+	ErrKeyedDuplicate,
+	ErrGroupNoCommonType,
+	ErrLazyConflict,
+	ErrNotInterfacePointer,
+	ErrExcludeInvalid,
+	ErrMissingDependency,
+	ErrNoProducerMakes,
+	ErrProducerReturnedNil,
+	ErrBuildPassLimit,
+	ErrValueLimit,
+	ErrDuplicateProducer,
+	ErrUnused,
+	ErrNoMain,
+	ErrMultipleMain,
+}
+
+// RegisterExitCode makes ExitCode return code for errors matching err (using errors.Is).
+// Registered errors are checked in registration order before the built in mappings.
+func RegisterExitCode(err error, code int) {
+	exitCodesLock.Lock()
+	defer exitCodesLock.Unlock()
+	exitCodes = append(exitCodes, exitCode{err: err, code: code})
+}
+
+// ExitCode returns a process exit code for the errors returned by Run.  The first error is the
+// cause of the shutdown (any following errors are from closing) so it alone decides the code.
+func ExitCode(errs []error) int {
+	if len(errs) == 0 {
+		return ExitCodeOK
+	}
+	err := errs[0]
+
+	exitCodesLock.Lock()
+	defer exitCodesLock.Unlock()
+	for _, v := range exitCodes {
+		if errors.Is(err, v.err) {
+			return v.code
+		}
+	}
+
+	if errors.Is(err, ErrAbort) {
+		return ExitCodeOK
+	}
+	if errors.Is(err, ErrShutdownSignal) {
+		return ExitCodeSignal
+	}
+	for _, v := range wiringErrors {
+		if errors.Is(err, v) {
+			return ExitCodeWiring
+		}
+	}
+	return ExitCodeError
+}
@@ -0,0 +1,66 @@
+package runner
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// AddFactory see Runner interface doc
+func (r *runner) AddFactory(factoryProducer interface{}) error {
+	itemType, err := r.producerType(factoryProducer)
+	if err != nil {
+		return err
+	}
+	types := outTypes(itemType)
+	if len(types) != 1 || types[0].Kind() != reflect.Interface {
+		return ErrFactoryInvalid
+	}
+	factoryType := reflect.FuncOf(nil, []reflect.Type{types[0], errorType}, false)
+	if r.factories[factoryType] != nil {
+		return fmt.Errorf("%w type: %v", ErrFactoryConflict, factoryType)
+	}
+	r.factories[factoryType] = &producer{value: reflect.ValueOf(factoryProducer), lazy: true}
+	return nil
+}
+
+// isFactoryType reports whether valueType is func() (T, error) with T an interface
+func isFactoryType(valueType reflect.Type) bool {
+	return valueType.Kind() == reflect.Func &&
+		valueType.NumIn() == 0 &&
+		valueType.NumOut() == 2 &&
+		valueType.Out(0).Kind() == reflect.Interface &&
+		valueType.Out(1) == errorType
+}
+
+// factory finds the inputs of the factory producer p and returns a func of factoryType that
+// calls p with them, saving any closers it makes
+func (r *runner) factory(p *producer, factoryType reflect.Type) (reflect.Value, error) {
+	in, err := r.inputs(p)
+	if err != nil {
+		return nilValue, err
+	}
+	return reflect.MakeFunc(factoryType, func([]reflect.Value) []reflect.Value {
+		results := callProducer(p, in)
+		value := reflect.New(factoryType.Out(0)).Elem()
+		errValue := reflect.New(errorType).Elem()
+		if len(results) == 2 && !results[1].IsNil() {
+			r.saveIfClosers(results[0], p.phase)
+			errValue.Set(results[1])
+			return []reflect.Value{value, errValue}
+		}
+		if isNil(results[0]) {
+			errValue.Set(reflect.ValueOf(
+				fmt.Errorf(
+					"%w func: %v type: %v",
+					ErrProducerReturnedNil,
+					funcName(p.value),
+					factoryType.Out(0),
+				),
+			))
+			return []reflect.Value{value, errValue}
+		}
+		r.saveIfCloser(results[0], p.phase)
+		value.Set(results[0])
+		return []reflect.Value{value, errValue}
+	}), nil
+}
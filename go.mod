@@ -1,6 +1,6 @@
 module github.com/blbgo/runner
 
-go 1.14
+go 1.18
 
 require (
 	github.com/blbgo/general v0.1.0
@@ -0,0 +1,85 @@
+package runner
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// AddToGroup see Runner interface doc
+func (r *runner) AddToGroup(name string, producerFunc interface{}) error {
+	_, err := r.producerType(producerFunc)
+	if err != nil {
+		return err
+	}
+	r.producers = append(
+		r.producers,
+		&producer{value: reflect.ValueOf(producerFunc), index: len(r.producers), group: name},
+	)
+	return nil
+}
+
+// Group see Getter interface doc
+func (r *runner) Group(name string) []interface{} {
+	return append([]interface{}{}, r.groups[name]...)
+}
+
+// handleGroupValue adds result, or its elements if it is a slice, to the group of p
+func (r *runner) handleGroupValue(p *producer, result reflect.Value) error {
+	values := []reflect.Value{result}
+	if isInterfaceSlice(result.Type()) {
+		values = values[:0]
+		for i := 0; i < result.Len(); i++ {
+			values = append(values, result.Index(i))
+		}
+	}
+	for _, value := range values {
+		if isNil(value) {
+			return fmt.Errorf(
+				"%w func: %v group: %v",
+				ErrProducerReturnedNil,
+				funcName(p.value),
+				p.group,
+			)
+		}
+		r.trackValue(p, value)
+		r.groups[p.group] = append(r.groups[p.group], value.Interface())
+	}
+	return nil
+}
+
+// ProvideGroup adds producer to the group name of r, like Runner.AddToGroup, but first checks
+// that producer returns T
+func ProvideGroup[T any](r Runner, name string, producer interface{}) error {
+	valueType := reflect.TypeOf((*T)(nil)).Elem()
+	producerType := reflect.TypeOf(producer)
+	if producerType == nil || producerType.Kind() != reflect.Func {
+		return ErrProducerNotFunc
+	}
+	for _, outType := range outTypes(producerType) {
+		if outType == valueType || outType == reflect.SliceOf(valueType) {
+			return r.AddToGroup(name, producer)
+		}
+	}
+	return fmt.Errorf("%w group: %v type: %v", ErrGroupType, name, valueType)
+}
+
+// GetGroup returns the values collected in the group name as T, the slice is empty (not nil) if
+// nothing was collected
+func GetGroup[T any](getter Getter, name string) ([]T, error) {
+	values := getter.Group(name)
+	result := make([]T, 0, len(values))
+	for _, v := range values {
+		value, ok := v.(T)
+		if !ok {
+			return nil, fmt.Errorf(
+				"%w group: %v type: %v value: %T",
+				ErrGroupType,
+				name,
+				reflect.TypeOf((*T)(nil)).Elem(),
+				v,
+			)
+		}
+		result = append(result, value)
+	}
+	return result, nil
+}
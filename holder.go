@@ -0,0 +1,93 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sync/atomic"
+)
+
+// Holder is provided by ProvideHolder in place of the value of T its producer returns, so the
+// value can be swapped while running (like reloaded config) without rebuilding.  Get and Set are
+// safe to call from any goroutine.
+type Holder[T any] interface {
+	// Get returns the current value
+	Get() T
+	// Set replaces the current value
+	Set(value T)
+}
+
+// holderBox is what holder stores so the atomic.Value always holds the same concrete type
+type holderBox[T any] struct {
+	value T
+}
+
+type holder[T any] struct {
+	current atomic.Value
+}
+
+// ProvideHolder adds a producer of T to r that is provided as Holder[T] holding the value it
+// returns.  producer must return T and optionally an error.  When closing, the value held then
+// is closed if it is an io.Closer.
+func ProvideHolder[T any](r Runner, producer interface{}) error {
+	valueType := reflect.TypeOf((*T)(nil)).Elem()
+	producerValue := reflect.ValueOf(producer)
+	producerType := reflect.TypeOf(producer)
+	if producerType == nil || producerType.Kind() != reflect.Func {
+		return ErrProducerNotFunc
+	}
+	types := outTypes(producerType)
+	if len(types) != 1 || types[0] != valueType {
+		return fmt.Errorf("%w type: %v producer: %v", ErrHolderInvalid, valueType, producerType)
+	}
+	in := make([]reflect.Type, producerType.NumIn())
+	for i := range in {
+		in[i] = producerType.In(i)
+	}
+	// the wrapper always returns an error so a nil value can be reported
+	out := []reflect.Type{reflect.TypeOf((*Holder[T])(nil)).Elem(), errorType}
+	wrapperType := reflect.FuncOf(in, out, producerType.IsVariadic())
+	wrapper := reflect.MakeFunc(wrapperType, func(args []reflect.Value) []reflect.Value {
+		var results []reflect.Value
+		if producerType.IsVariadic() {
+			results = producerValue.CallSlice(args)
+		} else {
+			results = producerValue.Call(args)
+		}
+		if len(results) == 2 && !results[1].IsNil() {
+			return []reflect.Value{reflect.Zero(out[0]), results[1]}
+		}
+		errValue := reflect.New(errorType).Elem()
+		if isNil(results[0]) {
+			errValue.Set(reflect.ValueOf(fmt.Errorf(
+				"%w func: %v type: %v",
+				ErrProducerReturnedNil,
+				funcName(producerValue),
+				valueType,
+			)))
+			return []reflect.Value{reflect.Zero(out[0]), errValue}
+		}
+		h := &holder[T]{}
+		h.Set(results[0].Interface().(T))
+		return []reflect.Value{reflect.ValueOf(Holder[T](h)), errValue}
+	})
+	return r.Add(wrapper.Interface())
+}
+
+// Get see Holder interface doc
+func (r *holder[T]) Get() T {
+	return r.current.Load().(holderBox[T]).value
+}
+
+// Set see Holder interface doc
+func (r *holder[T]) Set(value T) {
+	r.current.Store(holderBox[T]{value: value})
+}
+
+// Close closes the value held if it is an io.Closer
+func (r *holder[T]) Close() error {
+	if closer, ok := interface{}(r.Get()).(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -2,22 +2,143 @@
 package runner
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"io"
 	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/blbgo/general"
 )
 
 type runner struct {
-	closeTimeout  time.Duration
-	produceCounts map[reflect.Type]int
-	provideSlice  map[reflect.Type]bool
-	producers     []reflect.Value
-	values        map[reflect.Type]reflect.Value
-	closers       []interface{}
+	parent           *runner
+	closeTimeout     time.Duration
+	closeCtx         context.Context
+	closerTimeout    time.Duration
+	produceCounts    map[reflect.Type]int
+	provideSlice     map[reflect.Type]bool
+	producers        []*producer
+	lazy             map[reflect.Type]*producer
+	factories        map[reflect.Type]*producer
+	groups           map[string][]interface{}
+	interfaces       []reflect.Type
+	bindings         map[reflect.Type]reflect.Type
+	boundTypes       []reflect.Type
+	logger           general.Logger
+	failures         []error
+	failedTypes      map[reflect.Type]error
+	typeInfo         *typeInfo
+	buildStats       *buildStats
+	buildStart       time.Time
+	producedTypes    []reflect.Type
+	inventory        []InventoryEntry
+	docs             map[string]string
+	timings          Timings
+	produced         map[reflect.Type]bool
+	callOrder        []string
+	waiting          map[reflect.Type][]*producer
+	woken            []*producer
+	pending          []asyncResult
+	built            bool
+	buildPasses      int
+	maxBuildPasses   int
+	valueCount       int
+	maxValues        int
+	parallelBuild    int
+	interceptor      func(name string, call func() error) error
+	observer         Observer
+	errorTransform   func(err error) error
+	strictSingles    bool
+	failOnUnused     bool
+	unusedExempt     map[reflect.Type]bool
+	quietBugs        bool
+	shutdownGrace    time.Duration
+	values           map[reflect.Type]reflect.Value
+	seeds            []seed
+	closersLock      sync.Mutex
+	closers          []CloserInfo
+	closeExcluded    map[reflect.Type]bool
+	primaries        map[reflect.Type]bool
+	collect          map[reflect.Type]bool
+	collected        map[reflect.Type]reflect.Value
+	collectDone      bool
+	consumerBindings map[reflect.Type]map[reflect.Type]*consumerBinding
+	closeSorter      func(closers []CloserInfo) []CloserInfo
+	drainers         []Drainer
+	starters         []Starter
+	startWithMain    bool
+	guards           []ShutdownGuard
+	onClosed         []func(errs []error)
+	shutdownHooks    []func() error
+	onStarted        []func()
+	onShutdownLock   sync.Mutex
+	onShutdown       []func(err error)
+}
+
+// producer is a function added to the runner and how it should be treated
+type producer struct {
+	value reflect.Value
+	// lazy producers are not called by build, only when one of their types is first needed
+	lazy bool
+	// bestEffort producers that return an error are treated as if they were never added
+	bestEffort bool
+	// index is the order the producer was added in
+	index int
+	// phase is the close phase of the values the producer returns, see AddInPhase
+	phase int
+	// timeout limits how long calling the producer may take, see AddWithTimeout
+	timeout time.Duration
+	// attempts is how many times the producer is called while it returns an error, with backoff
+	// between them, see AddWithRetry
+	attempts int
+	backoff  time.Duration
+	// key is the key of the producer's value in the map it is provided in, see AddKeyed
+	key reflect.Value
+	// group is the name of the group the producer's values are collected in, see AddToGroup
+	group string
+	// async producers return a channel the value is received from, see AddAsync
+	async        bool
+	asyncTimeout time.Duration
+	// primary producers provide the single value of their types as well as slice elements, see
+	// AddPrimary
+	primary bool
+	// waitErr is why the producer is waiting while building
+	waitErr error
+	// resolveTime is how long finding the producer's inputs has taken so far, see SetObserver
+	resolveTime time.Duration
+}
+
+// waitError is returned by findParam when a dependency has producers that have not run yet
+type waitError struct {
+	paramType reflect.Type
+	// waitType is the type whose producers are being waited for
+	waitType reflect.Type
+}
+
+func (r *waitError) Error() string {
+	return fmt.Sprintf("%v type: %v", ErrMissingDependency, r.paramType)
+}
+
+func (r *waitError) Unwrap() error {
+	return ErrMissingDependency
+}
+
+// noProducerError is returned by findParam when nothing will ever provide a dependency
+type noProducerError struct {
+	paramType reflect.Type
+}
+
+func (r *noProducerError) Error() string {
+	return fmt.Sprintf("%v type: %v", ErrNoProducerMakes, r.paramType)
+}
+
+func (r *noProducerError) Unwrap() error {
+	return ErrNoProducerMakes
 }
 
 // defaultCloseTimeout is the default timeout duration to wait for general.DelayCloser complete
@@ -28,191 +149,1503 @@ var nilValue = reflect.ValueOf(nil)
 var xvalueType = reflect.TypeOf((*reflect.Value)(nil)).Elem()
 var errorType = reflect.TypeOf((*error)(nil)).Elem()
 var mainType = reflect.TypeOf((*Main)(nil)).Elem()
+var mainFuncType = reflect.TypeOf((func() error)(nil))
+
+// mainFunc adapts a producer returning a plain func() error to Main
+type mainFunc func() error
+
+func (r mainFunc) Run() error { return r() }
+
+var contextMainType = reflect.TypeOf((*ContextMain)(nil)).Elem()
+
+// contextMain adapts a produced ContextMain to Main, runMain calls its RunContext method
+type contextMain struct {
+	ContextMain
+}
+
+func (r contextMain) Run() error { return r.RunContext(context.Background()) }
 
 // new creates a Runner
 func new() *runner {
 	return &runner{
-		closeTimeout:  defaultCloseTimeout,
-		produceCounts: make(map[reflect.Type]int),
-		provideSlice:  make(map[reflect.Type]bool),
-		values:        make(map[reflect.Type]reflect.Value),
+		closeTimeout:     defaultCloseTimeout,
+		produceCounts:    make(map[reflect.Type]int),
+		provideSlice:     make(map[reflect.Type]bool),
+		lazy:             make(map[reflect.Type]*producer),
+		factories:        make(map[reflect.Type]*producer),
+		groups:           make(map[string][]interface{}),
+		bindings:         make(map[reflect.Type]reflect.Type),
+		produced:         make(map[reflect.Type]bool),
+		failedTypes:      make(map[reflect.Type]error),
+		closeExcluded:    make(map[reflect.Type]bool),
+		primaries:        make(map[reflect.Type]bool),
+		collect:          make(map[reflect.Type]bool),
+		consumerBindings: make(map[reflect.Type]map[reflect.Type]*consumerBinding),
+		collected:        make(map[reflect.Type]reflect.Value),
+		docs:             make(map[string]string),
+		unusedExempt:     make(map[reflect.Type]bool),
+		values:           make(map[reflect.Type]reflect.Value),
 	}
 }
 
 // Add see Runner interface doc
-func (r *runner) add(producer interface{}) error {
+func (r *runner) Add(producerFunc interface{}) error {
+	itemType, err := r.producerType(producerFunc)
+	if err != nil {
+		return err
+	}
+
+	// note return types
+	types := r.providedTypes(itemType)
+	for _, outType := range types {
+		if r.lazy[outType] != nil {
+			return fmt.Errorf("%w type: %v", ErrLazyConflict, outType)
+		}
+	}
+	for _, outType := range types {
+		r.produceCounts[outType]++
+	}
+
+	// note slice requirements
+	for _, outType := range outTypes(itemType) {
+		if isInterfaceSlice(outType) {
+			// the returned elements join those of any other producers of the type
+			r.provideSlice[outType.Elem()] = true
+		}
+	}
+	for _, inType := range dependencies(itemType) {
+		if inType.Kind() == reflect.Slice {
+			r.provideSlice[inType.Elem()] = true
+		}
+	}
+
+	p := &producer{value: reflect.ValueOf(producerFunc), index: len(r.producers)}
+	r.producers = append(r.producers, p)
+	if r.waiting != nil {
+		// added by a producer while building, it is called in the next build pass
+		r.woken = append(r.woken, p)
+	}
+	return nil
+}
+
+// Register see Runner interface doc
+func (r *runner) Register(producerFunc interface{}) (Registration, error) {
+	err := r.Add(producerFunc)
+	if err != nil {
+		return Registration{}, err
+	}
+	return Registration{runner: r, index: len(r.producers) - 1}, nil
+}
+
+// Replace see Runner interface doc
+func (r *runner) Replace(registration Registration, producerFunc interface{}) error {
+	if registration.runner != r {
+		return ErrRegistrationInvalid
+	}
+	if r.built {
+		return ErrReplaceAfterRun
+	}
+	itemType, err := r.producerType(producerFunc)
+	if err != nil {
+		return err
+	}
+	p := r.producers[registration.index]
+	oldTypes := r.providedTypes(p.value.Type())
+	newTypes := r.providedTypes(itemType)
+	if !sameTypes(oldTypes, newTypes) {
+		return fmt.Errorf("%w types: %v replacement: %v", ErrReplaceMismatch, oldTypes, newTypes)
+	}
+	for _, inType := range dependencies(itemType) {
+		if inType.Kind() == reflect.Slice {
+			r.provideSlice[inType.Elem()] = true
+		}
+	}
+	p.value = reflect.ValueOf(producerFunc)
+	return nil
+}
+
+// AddWithTimeout see Runner interface doc
+func (r *runner) AddWithTimeout(timeout time.Duration, producerFunc interface{}) error {
+	err := r.Add(producerFunc)
+	if err != nil {
+		return err
+	}
+	r.producers[len(r.producers)-1].timeout = timeout
+	return nil
+}
+
+// AddWithRetry see Runner interface doc
+func (r *runner) AddWithRetry(attempts int, backoff time.Duration, producerFunc interface{}) error {
+	err := r.Add(producerFunc)
+	if err != nil {
+		return err
+	}
+	r.producers[len(r.producers)-1].attempts = attempts
+	r.producers[len(r.producers)-1].backoff = backoff
+	return nil
+}
+
+// AddPrimary see Runner interface doc
+func (r *runner) AddPrimary(producerFunc interface{}) error {
+	itemType, err := r.producerType(producerFunc)
+	if err != nil {
+		return err
+	}
+	types := r.providedTypes(itemType)
+	for _, outType := range types {
+		if r.primaries[outType] {
+			return fmt.Errorf("%w type: %v", ErrPrimaryConflict, outType)
+		}
+	}
+	err = r.Add(producerFunc)
+	if err != nil {
+		return err
+	}
+	for _, outType := range types {
+		r.primaries[outType] = true
+		r.provideSlice[outType] = true
+	}
+	r.producers[len(r.producers)-1].primary = true
+	return nil
+}
+
+// AddInPhase see Runner interface doc
+func (r *runner) AddInPhase(phase int, producerFunc interface{}) error {
+	err := r.Add(producerFunc)
+	if err != nil {
+		return err
+	}
+	r.producers[len(r.producers)-1].phase = phase
+	return nil
+}
+
+// AddAll see Runner interface doc
+func (r *runner) AddAll(producers ...interface{}) []error {
+	var errs []error
+	for _, producerFunc := range producers {
+		err := r.Add(producerFunc)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// AddFromStruct see Runner interface doc
+func (r *runner) AddFromStruct(structPtr interface{}, strict bool) []error {
+	value := reflect.ValueOf(structPtr)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return []error{fmt.Errorf("%w got: %T", ErrStructInvalid, structPtr)}
+	}
+	value = value.Elem()
+	var errs []error
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		if field.Type.Kind() != reflect.Func {
+			if strict {
+				errs = append(errs, fmt.Errorf("%w field: %v", ErrStructInvalid, field.Name))
+			}
+			continue
+		}
+		if value.Field(i).IsNil() {
+			errs = append(errs, fmt.Errorf("%w field: %v", ErrProducerNil, field.Name))
+			continue
+		}
+		err := r.Add(value.Field(i).Interface())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w field: %v", err, field.Name))
+		}
+	}
+	return errs
+}
+
+// AddBestEffort see Runner interface doc
+func (r *runner) AddBestEffort(producerFunc interface{}) error {
+	err := r.Add(producerFunc)
+	if err != nil {
+		return err
+	}
+	r.producers[len(r.producers)-1].bestEffort = true
+	return nil
+}
+
+// CallOrder see Runner interface doc
+func (r *runner) CallOrder() []string {
+	return r.callOrder
+}
+
+// BuildPasses see Runner interface doc
+func (r *runner) BuildPasses() int {
+	return r.buildPasses
+}
+
+// SetMaxBuildPasses see Runner interface doc
+func (r *runner) SetMaxBuildPasses(max int) {
+	r.maxBuildPasses = max
+}
+
+// SetStrictSingletons see Runner interface doc
+func (r *runner) SetStrictSingletons(strict bool) {
+	r.strictSingles = strict
+}
+
+// OnStarted see Runner interface doc
+func (r *runner) OnStarted(callback func()) {
+	r.onStarted = append(r.onStarted, callback)
+}
+
+// SetShutdownGrace see Runner interface doc
+func (r *runner) SetShutdownGrace(grace time.Duration) {
+	r.shutdownGrace = grace
+}
+
+// SetMaxValues see Runner interface doc
+func (r *runner) SetMaxValues(max int) {
+	r.maxValues = max
+}
+
+// ValueCount see Runner interface doc
+func (r *runner) ValueCount() int {
+	return r.valueCount
+}
+
+// CloserCount see Runner interface doc
+func (r *runner) CloserCount() int {
+	return len(r.closerList())
+}
+
+// SetProducerInterceptor see Runner interface doc
+func (r *runner) SetProducerInterceptor(interceptor func(name string, call func() error) error) {
+	r.interceptor = interceptor
+}
+
+// SetErrorTransform see Runner interface doc
+func (r *runner) SetErrorTransform(transform func(err error) error) {
+	r.errorTransform = transform
+}
+
+// SetQuietBugs see Runner interface doc
+func (r *runner) SetQuietBugs(quiet bool) {
+	r.quietBugs = quiet
+}
+
+// SetLogger see Runner interface doc
+func (r *runner) SetLogger(logger general.Logger) {
+	r.logger = logger
+}
+
+// logf logs to the logger if one has been set
+func (r *runner) logf(format string, v ...interface{}) {
+	if r.logger != nil {
+		_ = r.logger.Logf(format, v...)
+	}
+}
+
+// AddLazy see Runner interface doc
+func (r *runner) AddLazy(lazyProducer interface{}) error {
+	itemType, err := r.producerType(lazyProducer)
+	if err != nil {
+		return err
+	}
+	for _, outType := range outTypes(itemType) {
+		if isInterfaceSlice(outType) {
+			return fmt.Errorf("%w lazy slice type: %v", ErrProducerInvalidReturns, outType)
+		}
+	}
+	types := r.providedTypes(itemType)
+	for _, outType := range types {
+		if r.produceCounts[outType] > 0 || r.lazy[outType] != nil {
+			return fmt.Errorf("%w type: %v", ErrLazyConflict, outType)
+		}
+	}
+	p := &producer{value: reflect.ValueOf(lazyProducer), lazy: true}
+	for _, outType := range types {
+		r.lazy[outType] = p
+	}
+	return nil
+}
+
+// AddGroup see Runner interface doc
+func (r *runner) AddGroup(producers ...interface{}) error {
+	var common map[reflect.Type]bool
+	for _, producer := range producers {
+		itemType, err := r.producerType(producer)
+		if err != nil {
+			return err
+		}
+		types := make(map[reflect.Type]bool)
+		for _, outType := range r.providedTypes(itemType) {
+			if common == nil || common[outType] {
+				types[outType] = true
+			}
+		}
+		common = types
+	}
+	if len(common) == 0 {
+		return ErrGroupNoCommonType
+	}
+
+	for _, producer := range producers {
+		err := r.Add(producer)
+		if err != nil {
+			return err
+		}
+	}
+	for outType := range common {
+		r.provideSlice[outType] = true
+	}
+	return nil
+}
+
+// AddInterfaces see Runner interface doc
+func (r *runner) AddInterfaces(interfacePtrs ...interface{}) error {
+	for _, interfacePtr := range interfacePtrs {
+		interfaceType, err := interfaceType(interfacePtr)
+		if err != nil {
+			return err
+		}
+		r.interfaces = append(r.interfaces, interfaceType)
+	}
+	return nil
+}
+
+// Bind see Runner interface doc
+func (r *runner) Bind(interfacePtr interface{}, concrete interface{}) error {
+	boundType, err := interfaceType(interfacePtr)
+	if err != nil {
+		return err
+	}
+	concreteType := reflect.TypeOf(concrete)
+	if concreteType == nil || concreteType.Kind() == reflect.Interface ||
+		!concreteType.Implements(boundType) {
+		return fmt.Errorf("%w type: %v concrete: %v", ErrBindNotImplemented, boundType, concreteType)
+	}
+	if bound, ok := r.bindings[boundType]; ok && bound != concreteType {
+		return fmt.Errorf("%w type: %v concrete: %v", ErrBindConflict, boundType, bound)
+	}
+	if _, ok := r.bindings[boundType]; !ok {
+		r.boundTypes = append(r.boundTypes, boundType)
+	}
+	r.bindings[boundType] = concreteType
+	return nil
+}
+
+// interfaceType returns the interface type interfacePtr points to, interfacePtr is expected to
+// be like (*SomeInterface)(nil)
+func interfaceType(interfacePtr interface{}) (reflect.Type, error) {
+	ptrType := reflect.TypeOf(interfacePtr)
+	if ptrType == nil || ptrType.Kind() != reflect.Ptr || ptrType.Elem().Kind() != reflect.Interface {
+		return nil, fmt.Errorf("%w got: %T", ErrNotInterfacePointer, interfacePtr)
+	}
+	return ptrType.Elem(), nil
+}
+
+// producerType validates producer and returns its type
+func (r *runner) producerType(producer interface{}) (reflect.Type, error) {
 	itemType := reflect.TypeOf(producer)
 	if itemType == nil {
-		return ErrProducerNil
+		return nil, ErrProducerNil
 	}
 	if itemType.Kind() != reflect.Func {
-		return ErrProducerNotFunc
+		return nil, ErrProducerNotFunc
 	}
 
-	// validate and note return types
-	outCount := itemType.NumOut()
-	// last return type error, ignore for now
-	if outCount > 0 && itemType.Out(outCount-1) == errorType {
-		outCount--
+	// validate return types
+	for _, outType := range outTypes(itemType) {
+		if len(r.provides(outType)) == 0 {
+			return nil, ErrProducerInvalidReturns
+		}
 	}
-	for i := 0; i < outCount; i++ {
-		outType := itemType.Out(i)
-		if outType.Kind() != reflect.Interface {
-			return ErrProducerInvalidReturns
+
+	// validate variadic element, it is provided as a slice so must be an interface
+	if itemType.IsVariadic() {
+		elemType := itemType.In(itemType.NumIn() - 1).Elem()
+		if elemType.Kind() != reflect.Interface {
+			return nil, fmt.Errorf(
+				"%w func: %v element type: %v",
+				ErrProducerVariadicInvalid,
+				funcName(reflect.ValueOf(producer)),
+				elemType,
+			)
 		}
-		r.produceCounts[outType]++
 	}
 
-	// validate inputs and note slice requirements
+	// validate inputs
 	for inCount := itemType.NumIn() - 1; inCount >= 0; inCount-- {
 		inType := itemType.In(inCount)
-		inKind := inType.Kind()
-		switch {
-		case inKind == reflect.Slice && inType.Elem().Kind() == reflect.Interface:
-			r.provideSlice[inType.Elem()] = true
-		case inKind == reflect.Interface:
-			// nothing to do just valid
-		default:
-			return ErrProducerInvalidInputs
+		if isParamsStruct(inType) {
+			for i := 0; i < inType.Elem().NumField(); i++ {
+				field := inType.Elem().Field(i)
+				if field.PkgPath != "" || !validDependency(field.Type) {
+					return nil, fmt.Errorf(
+						"%w params struct field: %v.%v",
+						ErrProducerInvalidInputs,
+						inType.Elem(),
+						field.Name,
+					)
+				}
+			}
+			continue
+		}
+		if !validDependency(inType) {
+			return nil, ErrProducerInvalidInputs
 		}
 	}
+	return itemType, nil
+}
 
-	r.producers = append(r.producers, reflect.ValueOf(producer))
-	return nil
+// validDependency reports whether a producer can depend on a value of dependencyType
+func validDependency(dependencyType reflect.Type) bool {
+	switch dependencyType.Kind() {
+	case reflect.Interface, reflect.Chan:
+		return true
+	case reflect.Func:
+		// valid as a factory, see AddFactory
+		return isFactoryType(dependencyType)
+	}
+	// valid, a slice (or a map from AddKeyed) will be provided
+	return isInterfaceSlice(dependencyType) || isInterfaceMap(dependencyType)
+}
+
+// isInterfaceSlice reports whether valueType is a slice of interfaces
+func isInterfaceSlice(valueType reflect.Type) bool {
+	return valueType.Kind() == reflect.Slice && valueType.Elem().Kind() == reflect.Interface
+}
+
+// isParamsStruct reports whether inType is a pointer to a struct whose fields are filled with
+// dependencies, a params struct
+func isParamsStruct(inType reflect.Type) bool {
+	return inType.Kind() == reflect.Ptr && inType.Elem().Kind() == reflect.Struct
+}
+
+// dependencies returns the types a producer of itemType depends on, the fields of params structs
+// are included instead of the struct itself
+func dependencies(itemType reflect.Type) []reflect.Type {
+	var types []reflect.Type
+	for i := 0; i < itemType.NumIn(); i++ {
+		inType := itemType.In(i)
+		if !isParamsStruct(inType) {
+			types = append(types, inType)
+			continue
+		}
+		for j := 0; j < inType.Elem().NumField(); j++ {
+			types = append(types, inType.Elem().Field(j).Type)
+		}
+	}
+	return types
+}
+
+// funcName returns the name of the function held by value
+func funcName(value reflect.Value) string {
+	f := runtime.FuncForPC(value.Pointer())
+	if f == nil {
+		return value.Type().String()
+	}
+	return f.Name()
+}
+
+// provides returns the types a value of outType is provided as.  An interface or channel is
+// provided as itself, a slice of interfaces as its element type, and other types are provided
+// as each interface added by AddInterfaces they implement.
+func (r *runner) provides(outType reflect.Type) []reflect.Type {
+	if outType.Kind() == reflect.Interface || outType.Kind() == reflect.Chan {
+		return []reflect.Type{outType}
+	}
+	if outType == mainFuncType {
+		return []reflect.Type{mainType}
+	}
+	if isInterfaceSlice(outType) {
+		return []reflect.Type{outType.Elem()}
+	}
+	var types []reflect.Type
+	for _, interfaceType := range r.interfaces {
+		if bound, ok := r.bindings[interfaceType]; ok && bound != outType {
+			continue
+		}
+		if outType.Implements(interfaceType) {
+			types = append(types, interfaceType)
+		}
+	}
+	for _, boundType := range r.boundTypes {
+		if r.bindings[boundType] == outType && !containsType(types, boundType) {
+			types = append(types, boundType)
+		}
+	}
+	return types
+}
+
+// sameTypes reports whether a and b hold the same types in the same order
+func sameTypes(a []reflect.Type, b []reflect.Type) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// containsType reports whether types includes valueType
+func containsType(types []reflect.Type, valueType reflect.Type) bool {
+	for _, v := range types {
+		if v == valueType {
+			return true
+		}
+	}
+	return false
+}
+
+// providedTypes returns all the types a producer of itemType provides
+func (r *runner) providedTypes(itemType reflect.Type) []reflect.Type {
+	var types []reflect.Type
+	for _, outType := range outTypes(itemType) {
+		types = append(types, r.provides(outType)...)
+	}
+	return types
+}
+
+// outTypes returns the produced types of a producer type, the last return type if it is error is
+// not included
+func outTypes(itemType reflect.Type) []reflect.Type {
+	outCount := itemType.NumOut()
+	if outCount > 0 && itemType.Out(outCount-1) == errorType {
+		outCount--
+	}
+	types := make([]reflect.Type, outCount)
+	for i := range types {
+		types[i] = itemType.Out(i)
+	}
+	return types
 }
 
 // Run see Runner interface doc
-func (r *runner) run() []error {
-	errs := r.build()
+func (r *runner) Run() []error {
+	return r.RunContext(context.Background())
+}
+
+// RunContext see Runner interface doc
+func (r *runner) RunContext(ctx context.Context) []error {
+	errs := r.build(ctx)
+	if len(errs) == 1 && errors.Is(errs[0], ErrAbort) {
+		// a producer decided there is nothing to do, not an error
+		return r.closeContext(ctx, nil)
+	}
 	if errs != nil {
-		return r.close(errs)
+		return r.closeContext(ctx, errs)
 	}
 
+	// nil out producers and provideSlice so memory can be garbage collected, the values (and
+	// what is needed to call lazy producers) are kept while Main runs for child Runners
+	r.producers = nil
+	r.provideSlice = nil
+
 	// get the Main interface
+	main, err := r.findMain()
+	if err != nil {
+		errs = append(errs, err)
+		return r.closeContext(ctx, errs)
+	}
+
+	var started chan error
+	mainCtx := ctx
+	if r.startWithMain {
+		// a ContextMain is told to shutdown if starting fails, a plain Main can not be
+		cancel := func() {}
+		if _, ok := main.(ContextMain); ok {
+			mainCtx, cancel = context.WithCancel(ctx)
+			defer cancel()
+		}
+		started = make(chan error, 1)
+		go func() {
+			err := r.start(ctx)
+			if err != nil {
+				r.logf("runner: starting failed, shutting down: %v", err)
+				cancel()
+			}
+			started <- err
+		}()
+	} else if err := r.start(ctx); err != nil {
+		errs = append(errs, err)
+		return r.closeContext(ctx, errs)
+	}
+
+	err = r.runMain(ctx, mainCtx, main)
+	r.timings.MainReturned = time.Now()
+
+	// values no longer needed, set to null to maybe free memory.  A Main still running past the
+	// shutdown grace may yet use them (through a child Runner) so then they are kept.
+	if !errors.Is(err, ErrShutdownGraceTimeout) {
+		r.values = nil
+		r.produceCounts = nil
+		r.lazy = nil
+	}
+	r.notifyShutdown(err)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if started != nil {
+		if err := <-started; err != nil {
+			errs = append(errs, err)
+		}
+	}
+	errs = r.waitForGuards(errs)
+
+	return r.closeContext(ctx, errs)
+}
+
+// runMain calls main.Run (or RunContext with mainCtx for a ContextMain) in a goroutine so the
+// OnStarted callbacks can be called while it runs and, with a shutdown grace set, so that once
+// mainCtx is done it is only waited for until the grace runs out.  mainCtx is ctx, the run
+// context, or one derived from it that is also canceled if a concurrent Starter fails.
+func (r *runner) runMain(ctx context.Context, mainCtx context.Context, main Main) error {
+	done := make(chan error, 1)
+	go func() {
+		if contextMain, ok := main.(ContextMain); ok {
+			done <- contextMain.RunContext(mainCtx)
+			return
+		}
+		done <- main.Run()
+	}()
+	for _, callback := range r.onStarted {
+		callback()
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-mainCtx.Done():
+		if ctx.Err() != nil {
+			r.timings.ShutdownRequested = time.Now()
+		}
+	}
+	if r.shutdownGrace <= 0 {
+		return <-done
+	}
+	timer := time.NewTimer(r.shutdownGrace)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return ErrShutdownGraceTimeout
+	}
+}
+
+// notifyShutdown calls the ShutdownNotifier callbacks with err in registration order, a callback
+// that takes longer than the close timeout is logged and left running
+func (r *runner) notifyShutdown(err error) {
+	r.onShutdownLock.Lock()
+	callbacks := r.onShutdown
+	r.onShutdownLock.Unlock()
+	for i, callback := range callbacks {
+		done := make(chan struct{})
+		go func(callback func(err error)) {
+			defer close(done)
+			callback(err)
+		}(callback)
+		timer := time.NewTimer(r.closeTimeout)
+		select {
+		case <-done:
+		case <-timer.C:
+			r.logf("shutdown callback %v did not return within %v", i, r.closeTimeout)
+		}
+		timer.Stop()
+	}
+}
+
+// findMain returns the produced Main.  If no producer returned Main itself a single produced
+// value of an interface type that embeds Main is used, failing that a single produced value
+// whose implementation has the Run method, so a service can be its own entry point.
+func (r *runner) findMain() (Main, error) {
+	main, _, err := r.findMainTypes()
+	return main, err
+}
+
+// findMainTypes is findMain also returning the types the Main was provided as
+func (r *runner) findMainTypes() (Main, []reflect.Type, error) {
+	if mains, ok := r.values[reflect.SliceOf(mainType)]; ok && mains.Len() > 1 {
+		// more than one producer provided Main, like a func() error and a Main
+		types := make([]reflect.Type, mains.Len())
+		for i := range types {
+			types[i] = mains.Index(i).Elem().Type()
+		}
+		return nil, nil, fmt.Errorf("%w types: %v", ErrMultipleMain, types)
+	}
 	mainValue, ok := r.values[mainType]
-	if !ok {
-		errs = append(errs, ErrNoMain)
-		return r.close(errs)
+	if ok {
+		main, ok := mainValue.Interface().(Main)
+		if ok {
+			return main, []reflect.Type{mainType}, nil
+		}
+		errs := r.bug(nil, "BUG Main interface found but can not type assert to Main")
+		if len(errs) > 0 {
+			return nil, nil, errs[0]
+		}
 	}
-	main, ok := mainValue.Interface().(Main)
-	if !ok {
-		errs = append(
-			errs,
-			errors.New("BUG Main interface found but can not type assert to Main"),
+	if mainValue, ok := r.values[contextMainType]; ok {
+		main := contextMain{ContextMain: mainValue.Interface().(ContextMain)}
+		return main, []reflect.Type{contextMainType}, nil
+	}
+
+	// a type that embeds Main (like an App interface with Run and more) was declared as the
+	// entry point so it is preferred over values that only happen to implement Main
+	for _, statically := range []bool{true, false} {
+		var candidates []Main
+		var candidateTypes []reflect.Type
+		// mainTypes are all the types a candidate was provided as, there may be several
+		var mainTypes []reflect.Type
+		for _, valueType := range r.producedTypes {
+			value, ok := r.values[valueType]
+			if !ok || (statically && !valueType.Implements(mainType)) {
+				// provided as a slice or only checked in the second pass
+				continue
+			}
+			main, ok := value.Interface().(Main)
+			if !ok {
+				continue
+			}
+			mainTypes = append(mainTypes, valueType)
+			if containsMain(candidates, main) {
+				continue
+			}
+			candidates = append(candidates, main)
+			candidateTypes = append(candidateTypes, valueType)
+		}
+		switch len(candidates) {
+		case 0:
+			continue
+		case 1:
+			return candidates[0], mainTypes, nil
+		}
+		return nil, nil, fmt.Errorf("%w types: %v", ErrMultipleMain, candidateTypes)
+	}
+	return nil, nil, r.noMainError()
+}
+
+// noMainError wraps ErrNoMain with the produced types so it is clear what was built, a produced
+// value with a Run method that does not match Main is pointed out as it was most likely meant to
+// be Main
+func (r *runner) noMainError() error {
+	var mismatched []string
+	for _, valueType := range r.producedTypes {
+		value, ok := r.values[valueType]
+		if !ok {
+			continue
+		}
+		concrete := reflect.ValueOf(value.Interface())
+		method := concrete.MethodByName("Run")
+		if !method.IsValid() {
+			continue
+		}
+		mismatched = append(
+			mismatched,
+			fmt.Sprintf("%v has Run method %v not func() error", concrete.Type(), method.Type()),
 		)
-		return r.close(errs)
 	}
+	err := fmt.Errorf(
+		"%w none of the produced types implement runner.Main types: %v",
+		ErrNoMain,
+		r.producedTypes,
+	)
+	if len(mismatched) > 0 {
+		err = fmt.Errorf("%w, %v", err, strings.Join(mismatched, ", "))
+	}
+	return err
+}
 
-	// values no longer needed, set to null to maybe free memory
-	r.values = nil
+// containsMain reports whether main is already in mains, the same value may be produced as
+// several types
+func containsMain(mains []Main, main Main) bool {
+	if !reflect.TypeOf(main).Comparable() {
+		return false
+	}
+	for _, v := range mains {
+		if reflect.TypeOf(v) == reflect.TypeOf(main) && v == main {
+			return true
+		}
+	}
+	return false
+}
+
+// BuildOnly see Runner interface doc
+func (r *runner) BuildOnly() (Accessor, []error) {
+	errs := r.build(context.Background())
+	if errs != nil {
+		return nil, r.closeContext(context.Background(), errs)
+	}
+	return r, nil
+}
 
-	err := main.Run()
+// Value see Getter interface doc
+func (r *runner) Value(valueType reflect.Type) (interface{}, error) {
+	value, err := r.findParam(valueType)
 	if err != nil {
-		errs = append(errs, err)
+		return nil, err
 	}
+	return value.Interface(), nil
+}
 
-	return r.close(errs)
+// Close see Accessor interface doc
+func (r *runner) Close() []error {
+	return r.closeContext(context.Background(), nil)
 }
 
 // build calls all added functions once.  If any functions return errors or
 // any functions have dependencies that have not been added or there are any
-// circular references a slice of errors will be returned.
-func (r *runner) build() []error {
-	var waitingProducers []reflect.Value
-	var errs []error
-	for len(r.producers) > 0 {
-		for _, value := range r.producers {
-			err := r.resolveProvider(value)
-			if errors.Is(err, ErrMissingDependency) {
-				errs = append(errs, err)
-				waitingProducers = append(waitingProducers, value)
-			} else if err != nil {
+// circular references a slice of errors will be returned.  ctx is provided to
+// producers that depend on context.Context.
+//
+// Producers are resolved in passes.  The first pass tries every producer, a producer that can
+// not be resolved waits on the type it is missing and is tried in the next pass once the last
+// producer of that type has run.  Each pass is in the order producers were added so the order
+// producers are called in (and so closed in) does not depend on how waiting is tracked.
+func (r *runner) build(ctx context.Context) []error {
+	r.built = true
+	r.buildStart = time.Now()
+	if r.strictSingles {
+		if errs := r.checkSingletons(); errs != nil {
+			return errs
+		}
+	}
+	if errs := r.checkSeeds(); errs != nil {
+		return errs
+	}
+	r.provideBuiltins(ctx)
+	r.provideSeeds()
+	r.waiting = make(map[reflect.Type][]*producer)
+	ready := append([]*producer(nil), r.producers...)
+	for len(ready) > 0 {
+		r.buildPasses++
+		if r.maxBuildPasses > 0 && r.buildPasses > r.maxBuildPasses {
+			return []error{fmt.Errorf("%w passes: %v", ErrBuildPassLimit, r.maxBuildPasses)}
+		}
+		r.woken = nil
+		if err := r.buildPass(ready); err != nil {
+			return []error{err}
+		}
+		ready = r.woken
+		if len(ready) == 0 && len(r.pending) > 0 {
+			// nothing else can be built until the async producers deliver
+			err := r.awaitAsync()
+			if err != nil {
 				return []error{err}
 			}
+			ready = r.woken
 		}
+		if len(ready) == 0 && !r.collectDone && r.finishCollecting() {
+			// every value that will be provided has been, collections can be handed out
+			ready = r.woken
+		}
+		sort.Slice(ready, func(i, j int) bool { return ready[i].index < ready[j].index })
+	}
+	r.woken = nil
 
-		// did not resolve any producers
-		if len(waitingProducers) == len(r.producers) {
-			return errs
+	if len(r.waiting) > 0 {
+		var stuck []*producer
+		for _, producers := range r.waiting {
+			stuck = append(stuck, producers...)
+		}
+		sort.Slice(stuck, func(i, j int) bool { return stuck[i].index < stuck[j].index })
+		errs := make([]error, len(stuck))
+		for i, p := range stuck {
+			errs[i] = r.withConsumerChain(p, p.waitErr)
+		}
+		return errs
+	}
+	r.waiting = nil
+	if r.failOnUnused {
+		if err := r.checkUnused(); err != nil {
+			return []error{err}
+		}
+	}
+	r.completeBuiltins()
+	return nil
+}
+
+// buildPass calls the ready producers, those still missing dependencies are put in waiting
+func (r *runner) buildPass(ready []*producer) error {
+	if r.parallelBuild > 1 {
+		return r.buildPassParallel(ready)
+	}
+	for _, p := range ready {
+		err := r.resolveProvider(p)
+		if !r.waitIfWaitError(p, err) && err != nil {
+			return r.withConsumerChain(p, err)
 		}
-		errs = errs[:0]
-		waitingProducers, r.producers = r.producers[:0], waitingProducers
 	}
-	// nil out producers, produceCounts, and provideSlice so memory can be garbage collected
-	r.producers = nil
-	r.produceCounts = nil
-	r.provideSlice = nil
 	return nil
 }
 
+// waitIfWaitError puts p in waiting if err is a waitError, reporting whether it did
+func (r *runner) waitIfWaitError(p *producer, err error) bool {
+	var waitErr *waitError
+	if !errors.As(err, &waitErr) {
+		return false
+	}
+	p.waitErr = err
+	r.waiting[waitErr.waitType] = append(r.waiting[waitErr.waitType], p)
+	return true
+}
+
+// checkSingletons returns an error for each type that is depended on as a single value (or is
+// Main) but has more than one producer
+func (r *runner) checkSingletons() []error {
+	var errs []error
+	checked := map[reflect.Type]bool{}
+	check := func(valueType reflect.Type) {
+		if checked[valueType] {
+			return
+		}
+		checked[valueType] = true
+		if count := r.produceCounts[valueType]; count > 1 && !r.primaries[valueType] {
+			errs = append(
+				errs,
+				fmt.Errorf("%w type: %v producers: %v", ErrDuplicateProducer, valueType, count),
+			)
+		}
+	}
+	for _, p := range r.producers {
+		for _, inType := range dependencies(p.value.Type()) {
+			if inType.Kind() != reflect.Slice && inType.Kind() != reflect.Map {
+				check(inType)
+			}
+		}
+	}
+	check(mainType)
+	return errs
+}
+
+// MissingDependencies see Runner interface doc
+func (r *runner) MissingDependencies() []reflect.Type {
+	producers := append([]*producer(nil), r.producers...)
+	var others []*producer
+	for _, p := range r.lazy {
+		others = append(others, p)
+	}
+	for _, p := range r.factories {
+		others = append(others, p)
+	}
+	for _, bindings := range r.consumerBindings {
+		for _, binding := range bindings {
+			others = append(others, binding.producer)
+		}
+	}
+	sort.Slice(others, func(i, j int) bool {
+		return funcName(others[i].value) < funcName(others[j].value)
+	})
+	var missing []reflect.Type
+	for _, p := range append(producers, others...) {
+		for _, inType := range dependencies(p.value.Type()) {
+			if inType.Kind() == reflect.Slice || inType.Kind() == reflect.Map ||
+				r.staticallyProvided(inType) || r.boundFor(p, inType) ||
+				containsType(missing, inType) {
+				continue
+			}
+			missing = append(missing, inType)
+		}
+	}
+	return missing
+}
+
+// staticallyProvided reports whether something added to the runner (or the runner itself) will
+// provide a value of valueType
+func (r *runner) staticallyProvided(valueType reflect.Type) bool {
+	if builtinTypes[valueType] || r.factories[valueType] != nil || r.canProvide(valueType) ||
+		r.seeded(valueType) || r.parentProvides(valueType) {
+		return true
+	}
+	if valueType.Kind() == reflect.Chan && valueType.ChanDir() != reflect.BothDir {
+		return r.canProvide(reflect.ChanOf(reflect.BothDir, valueType.Elem()))
+	}
+	return false
+}
+
+// markProduced decrements the count of producers still to make valueType, when none remain the
+// producers waiting for it are woken for the next build pass
+func (r *runner) markProduced(valueType reflect.Type) {
+	r.produceCounts[valueType]--
+	if r.produceCounts[valueType] > 0 {
+		return
+	}
+	if producers, ok := r.waiting[valueType]; ok {
+		delete(r.waiting, valueType)
+		r.woken = append(r.woken, producers...)
+	}
+}
+
 // resolveProvider finds inputs, calls, and processes the results for a single provider
-func (r *runner) resolveProvider(provider reflect.Value) error {
-	providerType := provider.Type()
+func (r *runner) resolveProvider(p *producer) error {
+	in, err := r.findInputs(p)
+	if err != nil {
+		return err
+	}
+	if p.async {
+		return r.startAsync(p, in)
+	}
+	start := time.Now()
+	results, err := r.call(p, in)
+	r.observeCall(p, time.Since(start))
+	if err != nil {
+		return err
+	}
+	return r.handleResults(p, results)
+}
+
+// inputs finds the values to call a producer with
+func (r *runner) inputs(p *producer) ([]reflect.Value, error) {
+	providerType := p.value.Type()
 	in := make([]reflect.Value, providerType.NumIn())
 	for i := 0; i < len(in); i++ {
-		param, err := r.findParam(providerType.In(i))
+		inType := providerType.In(i)
+		if isParamsStruct(inType) {
+			params, err := r.paramsStruct(p, inType)
+			if err != nil {
+				return nil, err
+			}
+			in[i] = params
+			continue
+		}
+		param, err := r.findParamFor(p, inType)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		in[i] = param
 	}
-	results := provider.Call(in)
+	return in, nil
+}
+
+// paramsStruct allocates a params struct and fills its fields with dependencies
+func (r *runner) paramsStruct(p *producer, inType reflect.Type) (reflect.Value, error) {
+	params := reflect.New(inType.Elem())
+	fields := params.Elem()
+	for i := 0; i < fields.NumField(); i++ {
+		param, err := r.findParamFor(p, fields.Type().Field(i).Type)
+		if err != nil {
+			return nilValue, err
+		}
+		fields.Field(i).Set(param)
+	}
+	return params, nil
+}
+
+// call calls a producer with its inputs
+func (r *runner) call(p *producer, in []reflect.Value) ([]reflect.Value, error) {
+	r.callOrder = append(r.callOrder, funcName(p.value))
+	return r.invoke(p, in)
+}
+
+// invoke calls p with in, again while it returns an error if it was added with AddWithRetry.  It
+// only saves closers (which is locked) so parallel building can invoke several producers at once.
+func (r *runner) invoke(p *producer, in []reflect.Value) ([]reflect.Value, error) {
+	results, err := r.invokeOnce(p, in)
+	for attempt := 1; attempt < p.attempts && err == nil; attempt++ {
+		last := len(results) - 1
+		if last < 0 || p.value.Type().Out(last) != errorType || results[last].IsNil() {
+			break
+		}
+		r.logf(
+			"runner: producer %v failed attempt %v of %v: %v",
+			funcName(p.value),
+			attempt,
+			p.attempts,
+			results[last].Interface(),
+		)
+		// values returned along with the error may hold resources, they still need closing
+		for _, result := range results[:last] {
+			r.saveIfClosers(result, p.phase)
+		}
+		time.Sleep(p.backoff)
+		results, err = r.invokeOnce(p, in)
+	}
+	return results, err
+}
+
+// invokeOnce calls p with in, limited to its timeout if it has one
+func (r *runner) invokeOnce(p *producer, in []reflect.Value) ([]reflect.Value, error) {
+	if p.timeout <= 0 {
+		return r.intercept(p, in)
+	}
+	type called struct {
+		results []reflect.Value
+		err     error
+	}
+	done := make(chan called, 1)
+	go func() {
+		results, err := r.intercept(p, in)
+		done <- called{results: results, err: err}
+	}()
+	timer := time.NewTimer(p.timeout)
+	defer timer.Stop()
+	select {
+	case c := <-done:
+		return c.results, c.err
+	case <-timer.C:
+		// whatever the producer returns once it finishes is abandoned
+		return nil, fmt.Errorf(
+			"%w func: %v timeout: %v",
+			ErrProducerTimeout,
+			funcName(p.value),
+			p.timeout,
+		)
+	}
+}
+
+// intercept calls the producer p with in through the interceptor from SetProducerInterceptor if
+// there is one.  The error the interceptor returns becomes the producer's error, for a producer
+// without an error return it fails the build after saving any closers the producer returned.
+func (r *runner) intercept(p *producer, in []reflect.Value) ([]reflect.Value, error) {
+	if r.interceptor == nil {
+		return callProducer(p, in), nil
+	}
+	name := funcName(p.value)
+	var results []reflect.Value
+	err := r.interceptor(name, func() error {
+		results = callProducer(p, in)
+		if last := len(results) - 1; last >= 0 && p.value.Type().Out(last) == errorType &&
+			!results[last].IsNil() {
+			return results[last].Interface().(error)
+		}
+		return nil
+	})
+	if results == nil {
+		if err == nil {
+			err = ErrInterceptorNoCall
+		}
+		return nil, fmt.Errorf("%w func: %v", err, name)
+	}
+	last := len(results) - 1
+	if last >= 0 && p.value.Type().Out(last) == errorType {
+		errValue := reflect.New(errorType).Elem()
+		if err != nil {
+			errValue.Set(reflect.ValueOf(err))
+		}
+		results[last] = errValue
+		return results, nil
+	}
+	if err != nil {
+		for _, result := range results {
+			r.saveIfClosers(result, p.phase)
+		}
+		return nil, err
+	}
+	return results, nil
+}
+
+// callProducer calls the producer p with in
+func callProducer(p *producer, in []reflect.Value) []reflect.Value {
+	if p.value.Type().IsVariadic() {
+		// the last param is already a slice
+		return p.value.CallSlice(in)
+	}
+	return p.value.Call(in)
+}
+
+// handleResults processes the values returned by a producer
+func (r *runner) handleResults(p *producer, results []reflect.Value) error {
+	providerType := p.value.Type()
 	resultsCount := len(results)
 	if resultsCount > 0 && providerType.Out(resultsCount-1) == errorType {
 		result := results[resultsCount-1]
 		if !result.IsNil() {
+			// values returned along with the error may hold resources, they still need closing
+			for i := 0; i < resultsCount-1; i++ {
+				r.saveIfClosers(results[i], p.phase)
+			}
+			if p.bestEffort && !errors.Is(result.Interface().(error), ErrAbort) {
+				r.skipBestEffort(p, result.Interface().(error))
+				return nil
+			}
 			return result.Interface().(error)
 		}
 		resultsCount--
 	}
 	for i := 0; i < resultsCount; i++ {
 		result := results[i]
-		if result.IsNil() {
-			return fmt.Errorf("%w type: %v", ErrProducerReturnedNil, providerType.Out(i))
-		}
-		err := r.handleProvidedValue(result)
+		err := r.countValues(result)
 		if err != nil {
 			return err
 		}
+		if result.Type() == mainFuncType && !result.IsNil() {
+			var main Main = mainFunc(result.Interface().(func() error))
+			result = reflect.ValueOf(&main).Elem()
+		}
+		if p.key.IsValid() {
+			err = r.handleKeyedValue(p, result)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if p.group != "" {
+			err = r.handleGroupValue(p, result)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if isInterfaceSlice(result.Type()) {
+			err := r.handleProvidedSlice(p, result)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if isNil(result) {
+			return fmt.Errorf(
+				"%w func: %v type: %v",
+				ErrProducerReturnedNil,
+				funcName(p.value),
+				providerType.Out(i),
+			)
+		}
+		r.trackValue(p, result)
+		if result.Kind() == reflect.Interface {
+			err := r.handleProvidedValue(p, result)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		for _, interfaceType := range r.provides(result.Type()) {
+			value := reflect.New(interfaceType).Elem()
+			value.Set(result)
+			err := r.handleProvidedValue(p, value)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// trackValue registers value, returned by p, with everything that tracks produced values.  It is
+// saved to be closed, drained, started and guarded if it implements those and collected, then
+// recorded in the inventory and documentation once for each type it is provided as.
+func (r *runner) trackValue(p *producer, value reflect.Value) {
+	r.saveIfCloser(value, p.phase)
+	r.saveIfDrainer(value)
+	r.saveIfStarter(value)
+	r.saveIfGuard(value)
+	r.saveIfCollected(value)
+	for _, valueType := range r.provides(value.Type()) {
+		provided := reflect.New(valueType).Elem()
+		provided.Set(value)
+		r.addInventory(p, provided)
+		r.saveIfDocumented(provided)
+	}
+}
+
+// handleUncountedValue handles a value of a type no more producers were counted for, for example
+// from a producer added while building.  It is added as another element of the slice for its
+// type, joining the single value if there was one.
+func (r *runner) handleUncountedValue(value reflect.Value) {
+	valueType := value.Type()
+	r.logf("runner: value of type %v was not expected, providing it as a slice element", valueType)
+	sliceType := reflect.SliceOf(valueType)
+	aValue, ok := r.values[sliceType]
+	if !ok {
+		aValue = reflect.MakeSlice(sliceType, 0, 2)
+		if single, ok := r.values[valueType]; ok {
+			aValue = reflect.Append(aValue, single)
+		}
+	}
+	r.values[sliceType] = reflect.Append(aValue, value)
+}
+
+// countValues adds the values in result (the elements if it is a slice) to valueCount, failing
+// if that is more than allowed by SetMaxValues
+func (r *runner) countValues(result reflect.Value) error {
+	if isInterfaceSlice(result.Type()) {
+		r.valueCount += result.Len()
+	} else {
+		r.valueCount++
+	}
+	if r.maxValues > 0 && r.valueCount > r.maxValues {
+		return fmt.Errorf("%w max: %v", ErrValueLimit, r.maxValues)
+	}
+	return nil
+}
+
+// handleProvidedSlice adds the elements of a slice returned by a producer to the slice provided
+// for the element type.  A nil or empty slice just means the producer had nothing to add.
+func (r *runner) handleProvidedSlice(p *producer, result reflect.Value) error {
+	elemType := result.Type().Elem()
+	for i := 0; i < result.Len(); i++ {
+		if isNil(result.Index(i)) {
+			return fmt.Errorf(
+				"%w func: %v type: %v index: %v",
+				ErrProducerReturnedNil,
+				funcName(p.value),
+				result.Type(),
+				i,
+			)
+		}
+		r.trackValue(p, result.Index(i))
+	}
+	if !r.produced[elemType] {
+		r.produced[elemType] = true
+		r.producedTypes = append(r.producedTypes, elemType)
+	}
+	if r.produceCounts[elemType] > 0 {
+		r.markProduced(elemType)
 	}
+	sliceType := reflect.SliceOf(elemType)
+	aValue, ok := r.values[sliceType]
+	if !ok {
+		aValue = reflect.MakeSlice(sliceType, 0, result.Len())
+	}
+	r.values[sliceType] = reflect.AppendSlice(aValue, result.Convert(sliceType))
 	return nil
 }
 
+// skipBestEffort records the error from a best effort producer and stops waiting for its types
+func (r *runner) skipBestEffort(p *producer, err error) {
+	name := funcName(p.value)
+	r.logf("runner: best effort producer %v failed: %v", name, err)
+	err = fmt.Errorf("%v: %w", name, err)
+	r.failures = append(r.failures, err)
+	for _, outType := range r.providedTypes(p.value.Type()) {
+		if r.failedTypes[outType] == nil {
+			r.failedTypes[outType] = err
+		}
+		// several producers means a slice even if only one of them succeeds, so consumers see
+		// the same type regardless of which producers failed
+		if r.produceCounts[outType] > 1 {
+			r.provideSlice[outType] = true
+		}
+		r.markProduced(outType)
+	}
+}
+
+// isNil reports whether value is nil, without panicking for kinds that can not be nil.  An
+// interface holding a typed nil (like (*Foo)(nil)) is also reported as nil since using it would
+// most likely cause a nil dereference later.
+func isNil(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Interface:
+		return value.IsNil() || isNil(value.Elem())
+	case reflect.Chan, reflect.Func, reflect.Map, reflect.Ptr, reflect.Slice:
+		return value.IsNil()
+	}
+	return false
+}
+
+// findParam returns the value for a dependency of paramType.  A slice dependency is one of three
+// cases depending on the producers of its element type:
+//   - none were added (or all were best effort and failed): an empty slice
+//   - some have not run yet: a waitError so the consumer waits until the last of them has run
+//   - all have run: the slice of every value they provided, in the order they were provided
 func (r *runner) findParam(paramType reflect.Type) (reflect.Value, error) {
 	kind := paramType.Kind()
+	if kind == reflect.Chan && paramType.ChanDir() != reflect.BothDir {
+		// a directional channel can be satisfied by a bidirectional one with the same element
+		bothType := reflect.ChanOf(reflect.BothDir, paramType.Elem())
+		if !r.canProvide(paramType) && r.canProvide(bothType) {
+			value, err := r.findParam(bothType)
+			if err != nil {
+				return nilValue, err
+			}
+			return value.Convert(paramType), nil
+		}
+	}
+	if kind == reflect.Slice && r.collect[paramType.Elem()] {
+		return r.findCollected(paramType)
+	}
 	if kind == reflect.Slice {
+		// counts only reach zero once every producer of the element type has run, so a slice
+		// is never handed out with some of its elements missing
 		if r.produceCounts[paramType.Elem()] > 0 {
-			return nilValue, fmt.Errorf("%w type: %v", ErrMissingDependency, paramType)
+			return nilValue, &waitError{paramType: paramType, waitType: paramType.Elem()}
 		}
 	} else if r.produceCounts[paramType] > 0 {
-		return nilValue, fmt.Errorf("%w type: %v", ErrMissingDependency, paramType)
+		return nilValue, &waitError{paramType: paramType, waitType: paramType}
 	}
 
+	if p := r.factories[paramType]; p != nil {
+		return r.factory(p, paramType)
+	}
 	param, ok := r.values[paramType]
 	if !ok {
+		if p := r.lazy[paramType]; p != nil {
+			return r.resolveLazy(p, paramType)
+		}
+		if r.parentProvides(paramType) {
+			// read through to the parent, what that makes is closed by the parent
+			return r.parent.findParam(paramType)
+		}
+		if kind == reflect.Map {
+			// like a slice nothing added with the key type means an empty map
+			return reflect.MakeMap(paramType), nil
+		}
 		if kind != reflect.Slice {
 			// bad will be no way to resolve this type ever
-			return nilValue, fmt.Errorf("%w type: %v", ErrNoProducerMakes, paramType)
+			return nilValue, &noProducerError{paramType: paramType}
 		}
-		// need a slice of something that will not be produced, seems like providing an empty slice
-		// would be the correct behavior instead of an error
+		// nothing provided the element type and nothing will, a slice dependency means zero or
+		// more so that is an empty slice rather than an error
 		return reflect.MakeSlice(paramType, 0, 0), nil
 	}
 	return param, nil
 }
 
-func (r *runner) handleProvidedValue(value reflect.Value) error {
+// canProvide reports whether a value of valueType has been or will be provided
+func (r *runner) canProvide(valueType reflect.Type) bool {
+	if r.produceCounts[valueType] > 0 || r.lazy[valueType] != nil {
+		return true
+	}
+	_, ok := r.values[valueType]
+	return ok
+}
+
+// resolveLazy calls a lazy producer and returns its value of paramType.  While it is resolving
+// its types are removed from lazy so a circular reference reports a missing dependency instead
+// of recursing forever.
+func (r *runner) resolveLazy(p *producer, paramType reflect.Type) (reflect.Value, error) {
+	types := r.providedTypes(p.value.Type())
+	for _, outType := range types {
+		delete(r.lazy, outType)
+		r.produceCounts[outType]++
+	}
+	err := r.resolveProvider(p)
+	if err != nil {
+		// may be waiting for a dependency, allow it to be tried again later
+		for _, outType := range types {
+			r.lazy[outType] = p
+			r.produceCounts[outType]--
+		}
+		return nilValue, err
+	}
+	return r.values[paramType], nil
+}
+
+func (r *runner) handleProvidedValue(p *producer, value reflect.Value) error {
 	providedValueType := value.Type()
+	if !r.produced[providedValueType] {
+		r.produced[providedValueType] = true
+		r.producedTypes = append(r.producedTypes, providedValueType)
+	}
+	if p.lazy {
+		r.produceCounts[providedValueType]--
+		r.values[providedValueType] = value
+		return nil
+	}
 	waitForCount := r.produceCounts[providedValueType]
 	if waitForCount <= 0 {
-		return fmt.Errorf("BUG not waiting for produced type: %v", providedValueType)
+		r.handleUncountedValue(value)
+		return nil
 	}
 	// nothing wants a slice but a slice is what there will be
 	if waitForCount > 1 && !r.provideSlice[providedValueType] {
 		r.provideSlice[providedValueType] = true
 	}
-	r.produceCounts[providedValueType] = waitForCount - 1
-	r.saveIfCloser(value)
+	r.markProduced(providedValueType)
+	if p.primary {
+		r.values[providedValueType] = value
+	}
 	if !r.provideSlice[providedValueType] {
 		r.values[providedValueType] = value
 		return nil
@@ -229,46 +1662,3 @@ func (r *runner) handleProvidedValue(value reflect.Value) error {
 	)
 	return nil
 }
-
-func (r *runner) saveIfCloser(value reflect.Value) {
-	valueInterface := value.Interface()
-	switch valueInterface.(type) {
-	case io.Closer:
-		r.closers = append(r.closers, valueInterface)
-	case general.DelayCloser:
-		r.closers = append(r.closers, valueInterface)
-	}
-}
-
-// Close closes any values in the runner that implement the io.Closer or general.DelayCloser
-// interfaces.  They are closed in reverse creation order.  This will insure a values close will
-// be called before any of its dependencies.
-func (r *runner) close(errs []error) []error {
-	doneChan := make(chan error)
-	timer := time.NewTimer(r.closeTimeout)
-	for i := len(r.closers) - 1; i >= 0; i-- {
-		switch v := r.closers[i].(type) {
-		case io.Closer:
-			err := v.Close()
-			if err != nil {
-				errs = append(errs, err)
-			}
-		case general.DelayCloser:
-			v.Close(doneChan)
-			select {
-			case err, ok := <-doneChan:
-				if !ok {
-					return append(errs, errors.New("BUG runner DelayCloser doneChan closed"))
-				}
-				if err != nil {
-					errs = append(errs, err)
-				}
-			case <-timer.C:
-				return append(errs, ErrDelayCloserTimeout)
-			}
-		default:
-			errs = append(errs, errors.New("BUG runner has non closer in closers"))
-		}
-	}
-	return errs
-}
@@ -0,0 +1,31 @@
+package runner
+
+import "reflect"
+
+// InventoryEntry describes one value a producer provided
+type InventoryEntry struct {
+	// Interface is the type the value was provided as
+	Interface string
+	// Concrete is the type of the implementation behind Interface
+	Concrete string
+	// Producer is the name of the producer func that made the value
+	Producer string
+}
+
+// Inventory see Runner interface doc
+func (r *runner) Inventory() []InventoryEntry {
+	return append([]InventoryEntry(nil), r.inventory...)
+}
+
+// addInventory records that p provided value
+func (r *runner) addInventory(p *producer, value reflect.Value) {
+	concrete := value.Type()
+	if value.Kind() == reflect.Interface {
+		concrete = value.Elem().Type()
+	}
+	entry := InventoryEntry{Interface: value.Type().String(), Concrete: concrete.String()}
+	if p.value.IsValid() {
+		entry.Producer = funcName(p.value)
+	}
+	r.inventory = append(r.inventory, entry)
+}
@@ -0,0 +1,64 @@
+package runner
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// AddKeyed see Runner interface doc
+func (r *runner) AddKeyed(key interface{}, producerFunc interface{}) error {
+	keyType := reflect.TypeOf(key)
+	if keyType == nil || !keyType.Comparable() {
+		return fmt.Errorf("%w key: %T", ErrKeyedInvalid, key)
+	}
+	itemType, err := r.producerType(producerFunc)
+	if err != nil {
+		return err
+	}
+	types := outTypes(itemType)
+	if len(types) != 1 || types[0].Kind() != reflect.Interface {
+		return fmt.Errorf("%w type: %v", ErrKeyedInvalid, itemType)
+	}
+	r.produceCounts[reflect.MapOf(keyType, types[0])]++
+	for _, inType := range dependencies(itemType) {
+		if inType.Kind() == reflect.Slice {
+			r.provideSlice[inType.Elem()] = true
+		}
+	}
+	r.producers = append(r.producers, &producer{
+		value: reflect.ValueOf(producerFunc),
+		index: len(r.producers),
+		key:   reflect.ValueOf(key),
+	})
+	return nil
+}
+
+// isInterfaceMap reports whether valueType is a map of interfaces, provided by AddKeyed
+func isInterfaceMap(valueType reflect.Type) bool {
+	return valueType.Kind() == reflect.Map && valueType.Elem().Kind() == reflect.Interface
+}
+
+// handleKeyedValue adds result to the map provided for the key type of p
+func (r *runner) handleKeyedValue(p *producer, result reflect.Value) error {
+	if isNil(result) {
+		return fmt.Errorf(
+			"%w func: %v key: %v",
+			ErrProducerReturnedNil,
+			funcName(p.value),
+			p.key,
+		)
+	}
+	mapType := reflect.MapOf(p.key.Type(), result.Type())
+	keyed, ok := r.values[mapType]
+	if !ok {
+		keyed = reflect.MakeMap(mapType)
+		r.values[mapType] = keyed
+	}
+	if keyed.MapIndex(p.key).IsValid() {
+		return fmt.Errorf("%w type: %v key: %v", ErrKeyedDuplicate, mapType, p.key)
+	}
+	r.trackValue(p, result)
+	keyed.SetMapIndex(p.key, result)
+	r.markProduced(mapType)
+	return nil
+}
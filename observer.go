@@ -0,0 +1,35 @@
+package runner
+
+import (
+	"reflect"
+	"time"
+)
+
+// Observer is told how building went, see SetObserver
+type Observer interface {
+	// ProducerCalled is called after each producer called while building returns, in the order
+	// they were called.  resolve is the time spent finding its inputs, summed over every build
+	// pass that tried including those where it had to wait for a dependency, and call is the time
+	// the producer call itself took (with any retries).
+	ProducerCalled(name string, resolve time.Duration, call time.Duration)
+}
+
+// SetObserver see Runner interface doc
+func (r *runner) SetObserver(observer Observer) {
+	r.observer = observer
+}
+
+// findInputs is inputs adding the time it took to the resolve time of p
+func (r *runner) findInputs(p *producer) ([]reflect.Value, error) {
+	start := time.Now()
+	in, err := r.inputs(p)
+	p.resolveTime += time.Since(start)
+	return in, err
+}
+
+// observeCall tells the observer, if there is one, that p was called and took call
+func (r *runner) observeCall(p *producer, call time.Duration) {
+	if r.observer != nil {
+		r.observer.ProducerCalled(funcName(p.value), p.resolveTime, call)
+	}
+}
@@ -0,0 +1,58 @@
+package runner
+
+import (
+	"time"
+
+	"github.com/blbgo/general"
+)
+
+// Option configures a Runner, see RunWith
+type Option func(r Runner)
+
+// WithCloseTimeout is an Option calling Runner.SetCloseTimeout
+func WithCloseTimeout(timeout time.Duration) Option {
+	return func(r Runner) { r.SetCloseTimeout(timeout) }
+}
+
+// WithShutdownGrace is an Option calling Runner.SetShutdownGrace
+func WithShutdownGrace(grace time.Duration) Option {
+	return func(r Runner) { r.SetShutdownGrace(grace) }
+}
+
+// WithLogger is an Option calling Runner.SetLogger
+func WithLogger(logger general.Logger) Option {
+	return func(r Runner) { r.SetLogger(logger) }
+}
+
+// WithMaxBuildPasses is an Option calling Runner.SetMaxBuildPasses
+func WithMaxBuildPasses(max int) Option {
+	return func(r Runner) { r.SetMaxBuildPasses(max) }
+}
+
+// WithStrictSingletons is an Option calling Runner.SetStrictSingletons
+func WithStrictSingletons() Option {
+	return func(r Runner) { r.SetStrictSingletons(true) }
+}
+
+// RunWith is like Run but first applies opts to the Runner, in order, so the one call API can
+// be configured.  Any Runner method may be called from an Option.
+func RunWith(opts []Option, producers []interface{}) []error {
+	runner := New()
+	for _, opt := range opts {
+		opt(runner)
+	}
+
+	for _, v := range producers {
+		err := runner.Add(v)
+		if err != nil {
+			return []error{err}
+		}
+	}
+
+	return runner.Run()
+}
+
+// SetCloseTimeout see Runner interface doc
+func (r *runner) SetCloseTimeout(timeout time.Duration) {
+	r.closeTimeout = timeout
+}
@@ -0,0 +1,91 @@
+package runner
+
+import (
+	"reflect"
+	"sync"
+	"time"
+)
+
+// parallelCall is a producer called by a parallel build pass and what it returned
+type parallelCall struct {
+	producer *producer
+	in       []reflect.Value
+	results  []reflect.Value
+	err      error
+	took     time.Duration
+}
+
+// SetParallelBuild see Runner interface doc
+func (r *runner) SetParallelBuild(maxWorkers int) {
+	r.parallelBuild = maxWorkers
+}
+
+// buildPassParallel is buildPass for SetParallelBuild.  The inputs of every ready producer are
+// found first, then those with all their inputs are called concurrently by up to parallelBuild
+// workers, then the results are handled in the order the producers were added so errors and the
+// close order are the same from run to run.
+func (r *runner) buildPassParallel(ready []*producer) error {
+	var calls []*parallelCall
+	for _, p := range ready {
+		in, err := r.findInputs(p)
+		if r.waitIfWaitError(p, err) {
+			continue
+		}
+		if err == nil && p.async {
+			// async producers only start their work so are not worth a worker
+			err = r.startAsync(p, in)
+		}
+		if err != nil {
+			return r.withConsumerChain(p, err)
+		}
+		if !p.async {
+			r.callOrder = append(r.callOrder, funcName(p.value))
+			calls = append(calls, &parallelCall{producer: p, in: in})
+		}
+	}
+
+	workers := make(chan struct{}, r.parallelBuild)
+	var wg sync.WaitGroup
+	for _, c := range calls {
+		wg.Add(1)
+		workers <- struct{}{}
+		go func(c *parallelCall) {
+			defer wg.Done()
+			defer func() { <-workers }()
+			start := time.Now()
+			c.results, c.err = r.invoke(c.producer, c.in)
+			c.took = time.Since(start)
+		}(c)
+	}
+	wg.Wait()
+
+	for _, c := range calls {
+		r.observeCall(c.producer, c.took)
+	}
+	for i, c := range calls {
+		err := c.err
+		if err == nil {
+			err = r.handleResults(c.producer, c.results)
+		}
+		if err != nil {
+			// later producers were already called, what they made still needs closing
+			for _, later := range calls[i+1:] {
+				r.saveCallClosers(later)
+			}
+			return r.withConsumerChain(c.producer, err)
+		}
+	}
+	return nil
+}
+
+// saveCallClosers saves any closers among the results of c, which will not be handled
+func (r *runner) saveCallClosers(c *parallelCall) {
+	if c.err != nil {
+		return
+	}
+	for i, result := range c.results {
+		if c.producer.value.Type().Out(i) != errorType {
+			r.saveIfClosers(result, c.producer.phase)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package runner
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Plan see Runner interface doc
+func (r *runner) Plan() (string, error) {
+	remaining := make(map[reflect.Type]int)
+	for _, p := range r.producers {
+		for _, valueType := range r.planTypes(p) {
+			remaining[valueType]++
+		}
+	}
+	var order []*producer
+	var err error
+	todo := append([]*producer(nil), r.producers...)
+	for len(todo) > 0 {
+		var waiting []*producer
+		for _, p := range todo {
+			if len(r.planMissing(p, remaining)) > 0 {
+				waiting = append(waiting, p)
+				continue
+			}
+			order = append(order, p)
+			for _, valueType := range r.planTypes(p) {
+				remaining[valueType]--
+			}
+		}
+		if len(waiting) == len(todo) {
+			err = fmt.Errorf(
+				"%w func: %v types: %v",
+				ErrMissingDependency,
+				funcName(waiting[0].value),
+				r.planMissing(waiting[0], remaining),
+			)
+			break
+		}
+		todo = waiting
+	}
+
+	var plan strings.Builder
+	plan.WriteString("construction order:\n")
+	for i, p := range order {
+		fmt.Fprintf(&plan, "  %v. %v provides %v\n", i+1, funcName(p.value), r.planTypes(p))
+	}
+	closing := make([]*producer, len(order))
+	for i, p := range order {
+		closing[len(order)-1-i] = p
+	}
+	sort.SliceStable(closing, func(i, j int) bool { return closing[i].phase > closing[j].phase })
+	plan.WriteString("close order (of every producer, closers are closed in this order):\n")
+	for i, p := range closing {
+		fmt.Fprintf(&plan, "  %v. %v phase %v\n", i+1, funcName(p.value), p.phase)
+	}
+	return plan.String(), err
+}
+
+// planTypes returns the types p will provide, which for AddKeyed producers is their map type
+func (r *runner) planTypes(p *producer) []reflect.Type {
+	outType := outTypes(p.value.Type())
+	switch {
+	case p.group != "":
+		return nil
+	case p.key.IsValid():
+		return []reflect.Type{reflect.MapOf(p.key.Type(), outType[0])}
+	case p.async:
+		return r.provides(outType[0].Elem())
+	}
+	return r.providedTypes(p.value.Type())
+}
+
+// planMissing returns the dependencies of p that are not available, remaining has the number of
+// producers of each type not planned yet
+func (r *runner) planMissing(p *producer, remaining map[reflect.Type]int) []reflect.Type {
+	var missing []reflect.Type
+	for _, inType := range dependencies(p.value.Type()) {
+		waitType := inType
+		if inType.Kind() == reflect.Slice {
+			waitType = inType.Elem()
+		}
+		if remaining[waitType] > 0 {
+			missing = append(missing, inType)
+			continue
+		}
+		if inType.Kind() == reflect.Slice || inType.Kind() == reflect.Map ||
+			r.staticallyProvided(inType) || r.boundFor(p, inType) {
+			continue
+		}
+		missing = append(missing, inType)
+	}
+	return missing
+}
@@ -0,0 +1,20 @@
+package runner
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// AddSymbol see Runner interface doc
+func (r *runner) AddSymbol(symbol interface{}) error {
+	value := reflect.ValueOf(symbol)
+	// an exported variable is looked up as a pointer to it
+	if value.Kind() == reflect.Ptr && !value.IsNil() && value.Elem().Kind() == reflect.Func {
+		symbol = value.Elem().Interface()
+	}
+	err := r.Add(symbol)
+	if err != nil {
+		return fmt.Errorf("plugin symbol %T: %w", symbol, err)
+	}
+	return nil
+}
@@ -2,25 +2,512 @@
 package runner
 
 import (
+	"context"
 	"errors"
+	"reflect"
+	"time"
+
+	"github.com/blbgo/general"
 )
 
-// Main is an interface that must be provided by one (and only one) producer passed to Run.
+// Main is an interface that must be provided by one (and only one) producer passed to Run.  If no
+// producer returns Main itself, a single produced value whose implementation also has the Run
+// method is used as the Main, so the same object can be both a service and the entry point.  A
+// producer may also return a plain func() error, it is provided as the Main.
 type Main interface {
 	Run() error
 }
 
+// ContextMain can be provided instead of Main by an entry point that stops when a context is
+// done.  It is given the ctx passed to RunContext, so with a ctx from signal.NotifyContext a
+// signal makes it return and the close sequence follows, without the signalinterrupt package.
+// A produced Main that also implements ContextMain is run the same way.
+type ContextMain interface {
+	RunContext(ctx context.Context) error
+}
+
+// Runner collects producers so they can be run as a dependency stack
+type Runner interface {
+	// Add adds a producer, see the Run function for what a producer must be.  A producer may Add
+	// another while building, it is called in the next build pass.
+	Add(producer interface{}) error
+
+	// AddWithTimeout is like Add but if calling producer takes longer than timeout building
+	// fails with ErrProducerTimeout.  The producer is called in a goroutine, whatever it returns
+	// after the timeout is abandoned, so this is for constructors known to be risky, like ones
+	// that dial a remote service.
+	AddWithTimeout(timeout time.Duration, producer interface{}) error
+
+	// AddWithRetry is like Add but if producer returns an error it is called again, up to attempts
+	// calls in all with backoff between them, logging each failed attempt.  This is for
+	// constructors that fail transiently, like when a service they connect to is not up yet.
+	// Only the producer's own error is retried, not missing dependencies.
+	AddWithRetry(attempts int, backoff time.Duration, producer interface{}) error
+
+	// AddAsync adds a producer that starts expensive initialization concurrently.  It must
+	// return a receive only channel of an interface type, optionally with an error, like
+	// func(Config) (<-chan Store, error).  Building carries on with other producers and only
+	// waits for the value once nothing else can be built, so several slow producers warm up in
+	// parallel.  If no value arrives within timeout building fails with ErrProducerTimeout.
+	AddAsync(timeout time.Duration, producer interface{}) error
+
+	// AddInPhase is like Add but the values producer returns are closed in phase.  The close
+	// sequence closes phases in descending order, all closers in a phase (in reverse creation
+	// order) before any in the next.  Add uses phase zero.  For example ingress could be phase 3,
+	// workers 2, state 1 and connections the default 0.
+	AddInPhase(phase int, producer interface{}) error
+
+	// AddPrimary is like Add but the values producer returns are also provided alone, so while
+	// consumers of a slice of a type get the values of all its producers, consumers of the type
+	// itself get the primary one, like a main logging Sink among several.  Only one producer of
+	// a type can be primary, a second fails with ErrPrimaryConflict.  Consumers of the primary
+	// value still wait until every producer of the type has run.
+	AddPrimary(producer interface{}) error
+
+	// Register is like Add but also returns a Registration that Replace can use to swap the
+	// producer for another, for example in table tests that vary a single dependency.
+	Register(producer interface{}) (Registration, error)
+
+	// Replace swaps the producer registration was returned for with producer, which must
+	// provide the same types.  It can only be used before Run (or BuildOnly).
+	Replace(registration Registration, producer interface{}) error
+
+	// AddAll adds each producer like Add.  Unlike Add it does not stop at the first invalid
+	// producer, the errors for all invalid producers are returned.
+	AddAll(producers ...interface{}) []error
+
+	// AddFromStruct adds each exported func field of the struct structPtr points to like AddAll,
+	// so related wiring can be grouped in one struct.  Other exported fields are ignored, or with
+	// strict are errors, as are nil func fields.  The field name is included in each error.
+	AddFromStruct(structPtr interface{}, strict bool) []error
+
+	// AddFactory adds a producer that is not called when the Runner builds, instead producers
+	// that depend on func() (T, error), where T is the single interface factoryProducer returns,
+	// are given a factory that calls it each time.  The dependencies of factoryProducer are found
+	// once, when the factory is given out.  Each value the factory makes that implements
+	// io.Closer or general.DelayCloser is closed along with the produced values, in reverse
+	// creation order, even if it is made while Main runs.
+	AddFactory(factoryProducer interface{}) error
+
+	// AddToGroup adds a producer whose values are collected in the group name instead of being
+	// provided by type.  Values returned as a slice of interfaces are each added.  The group can
+	// be retrieved once building is complete with GetGroup, for example in Main.Run.
+	AddToGroup(name string, producer interface{}) error
+
+	// AddKeyed adds a producer whose value is provided as the element for key in a map, for
+	// dispatch tables like map[Command]Handler.  key may be any comparable value, its type and
+	// the interface producer returns give the map type.  Consumers of the map wait until every
+	// keyed producer of it has run, it is empty if nothing was added for it.  Adding two
+	// producers with the same key fails building with ErrKeyedDuplicate.
+	AddKeyed(key interface{}, producer interface{}) error
+
+	// AddGroup adds producers whose common return types are meant to be collected. Those types
+	// will always be provided as a slice even if only one producer makes them, so a consumer can
+	// not accidentally depend on a single value that another producer would later turn into a
+	// slice.
+	AddGroup(producers ...interface{}) error
+
+	// AddSymbol adds a producer looked up from a Go plugin, symbol is what plugin.Plugin.Lookup
+	// returned.  The symbol may be a function or an exported variable holding a function, errors
+	// describe why a symbol is not a valid producer.  Taking the symbol rather than the plugin
+	// keeps the plugin package, which needs cgo, out of programs that do not use plugins.
+	AddSymbol(symbol interface{}) error
+
+	// AddBestEffort adds a producer that is allowed to fail.  If it returns an error the error is
+	// logged and the Runner continues as if the producer had never been added.  Consumers of its
+	// types should therefore depend on a slice of them, which will be empty if it failed.  When
+	// several producers make a type the slice simply has fewer elements than there are producers.
+	AddBestEffort(producer interface{}) error
+
+	// SetQuietBugs makes internal invariant violations, which should never happen, be logged
+	// instead of being returned with the real errors.  Built with the runnerdebug tag they panic
+	// instead either way.
+	SetQuietBugs(quiet bool)
+
+	// SetLogger sets a logger the Runner reports non fatal problems to.  Unless a producer
+	// provides general.Logger itself, logger is also provided to producers that depend on it (a
+	// logger that discards everything is provided if none is set).
+	SetLogger(logger general.Logger)
+
+	// SetErrorTransform sets a func every error is passed through before it is returned (from
+	// building, Main and closing alike), to enrich or redact errors in one place.  Returning err
+	// unchanged or wrapped keeps errors.Is working, returning nil drops the error.  OnClosed
+	// callbacks are given the transformed errors.
+	SetErrorTransform(transform func(err error) error)
+
+	// AddInterfaces registers interfaces, given like (*SomeInterface)(nil), that producers
+	// returning non interface types are provided as.  A producer may then return a concrete type
+	// such as *MyLogger and its value will be provided as each registered interface the type
+	// implements.  Interfaces must be registered before adding producers that rely on them.
+	AddInterfaces(interfacePtrs ...interface{}) error
+
+	// CollectByInterface makes consumers of a slice of the interface given like
+	// (*Describable)(nil) get every produced value that implements it, whatever type it was
+	// provided as, for registries like health checks or component descriptions.  The slice is
+	// handed out once nothing else can be built, so values from producers that depend on it are
+	// not included.
+	CollectByInterface(interfacePtr interface{}) error
+
+	// Bind makes the interface given like (*Store)(nil) provided only by producers returning
+	// the concrete type of concrete, usually given like (*RedisStore)(nil).  The interface does
+	// not need to be registered with AddInterfaces, and if it is other concrete types that
+	// implement it are no longer provided as it.  Like AddInterfaces, Bind must be called before
+	// adding producers that rely on it.
+	Bind(interfacePtr interface{}, concrete interface{}) error
+
+	// BindFor makes producers that provide the consumer interface given like (*ServiceA)(nil)
+	// get the interface given like (*HTTPClient)(nil) from producer, while other consumers get
+	// the usual value, for example to give one service a client with a different timeout.
+	// producer must return only that interface (and an optional error).  It is called the first
+	// time it is needed and its value is shared by all producers of the consumer interface.  A
+	// second binding for the same consumer and interface fails with ErrBindConflict.
+	BindFor(consumerPtr interface{}, interfacePtr interface{}, producer interface{}) error
+
+	// Seed provides value as the interface given like (*SomeInterface)(nil) without a producer,
+	// for example to inject a mock in an integration test.  Producers that depend on the
+	// interface get value and, like a produced value, it is closed if it is an io.Closer or
+	// general.DelayCloser (after everything producers made).  value must implement the interface
+	// and nothing else may provide it, building fails with ErrSeedConflict if a producer does.
+	Seed(interfacePtr interface{}, value interface{}) error
+
+	// SeedUnmanaged is Seed for a value the Runner must not close, like a mock the test closes
+	SeedUnmanaged(interfacePtr interface{}, value interface{}) error
+
+	// AddLazy adds a producer that is not called when the Runner builds, instead it is called the
+	// first time a value it returns is needed by another producer.  Its values are singletons so
+	// no other producer may return the same types, and they are never collected into slices.
+	AddLazy(producer interface{}) error
+
+	// Run runs the added producers as described by the Run function
+	Run() []error
+
+	// MissingDependencies returns the types producers depend on that nothing added to the Runner
+	// provides, in the order producers were added, without calling any producers.  Slices are
+	// never missing, they are empty if nothing provides their element type.  It is meant for
+	// tooling and must be called before Run.
+	MissingDependencies() []reflect.Type
+
+	// Plan returns, as text for a person to read, the order producers will be called in and the
+	// order their values will be closed in, worked out from the producer types without calling
+	// anything.  If some producers could never be called the plan stops there and the error
+	// names the first of them and its missing dependencies.  Which values are closers is only
+	// known once they are made, so the close order lists every producer.
+	Plan() (string, error)
+
+	// CallOrder returns the names of the producer functions in the order they were called.  This
+	// is the actual initialization order, which differs from the order they were added in because
+	// producers are only called once their dependencies are available.
+	CallOrder() []string
+
+	// BuildPasses returns how many passes over the waiting producers building took.  Each pass
+	// calls every producer whose dependencies have become available, so a deep dependency chain
+	// takes many passes.
+	BuildPasses() int
+
+	// SetMaxBuildPasses limits how many passes building may take, exceeding it fails the build
+	// with ErrBuildPassLimit.  Zero, the default, means no limit.
+	SetMaxBuildPasses(max int)
+
+	// SetMaxValues limits how many values producers may return while building, exceeding it
+	// fails the build with ErrValueLimit.  Each element of a returned slice counts as a value.
+	// Zero, the default, means no limit.
+	SetMaxValues(max int)
+
+	// SetParallelBuild makes each build pass call the producers whose dependencies are all
+	// available concurrently, using up to maxWorkers goroutines, to speed up startup dominated by
+	// constructors that do I/O.  Their results are still handled in the order the producers were
+	// added, so errors and the close order do not depend on which finished first, though
+	// closers producers register with CloserRegistry are in the order they are registered.
+	// Producers must then be safe to call concurrently.  Zero or one, the default, builds
+	// sequentially.
+	SetParallelBuild(maxWorkers int)
+
+	// SetProducerInterceptor makes every producer called while building be called through
+	// interceptor, for cross cutting concerns like tracing spans or recovering panics.  name is
+	// the producer function name and call calls it, returning its error if it has one.  The
+	// interceptor controls the call so it can also change what happens, like retrying it.  The
+	// error it returns is used as the producer's error, and if it never calls call building fails
+	// with ErrInterceptorNoCall unless it returns an error of its own.
+	SetProducerInterceptor(interceptor func(name string, call func() error) error)
+
+	// SetObserver sets an Observer that is told, for each producer called while building, how
+	// long finding its inputs took apart from how long the call itself took.  That tells whether
+	// slow startup is from resolving dependencies over many build passes or a slow constructor.
+	SetObserver(observer Observer)
+
+	// Child returns a new Runner with its own lifecycle, for example for a plugin that is loaded
+	// and unloaded while the application runs.  Producers added to the child can depend on the
+	// values this Runner provides, a dependency the child does not provide itself is read through
+	// to this Runner (calling a lazy producer here if needed).  What the child provides is not
+	// visible here and the child has its own builtins, so its CloserRegistry and closers are its
+	// own: closing the child (when its Main returns or with the Accessor from BuildOnly) closes only
+	// what the child built and closing this Runner does not close the child.  So the values it
+	// depends on are there, build the child once this Runner has built (for example in Main.Run)
+	// and close it before this Runner closes.  The child starts with this Runner's logger and
+	// close timeouts.
+	Child() Runner
+
+	// ValueCount returns how many values producers have returned so far
+	ValueCount() int
+
+	// CloserCount returns how many values have been registered to be closed so far
+	CloserCount() int
+
+	// SetStrictSingletons makes building fail with ErrDuplicateProducer, before any producer is
+	// called, if a type that is depended on as a single value (or Main) has more than one
+	// producer.  Without it the extra producers turn the type into a slice and the dependent
+	// fails later with a less direct error.
+	SetStrictSingletons(strict bool)
+
+	// SetFailOnUnused makes building fail with ErrUnused if a produced type is not depended on,
+	// directly or through other producers, from Main (the one Run would run, including a service
+	// that is its own Main) or from a value that is a Starter, a Drainer or is closed.  That
+	// catches dead wiring at startup instead of it being built for nothing.  Types given in
+	// exemptPtrs, like (*SomeInterface)(nil), are used anyway and so is what they depend on, for
+	// example values only retrieved with BuildOnly's Accessor.
+	SetFailOnUnused(exemptPtrs ...interface{}) error
+
+	// SetCloseTimeout sets how long the whole close sequence may take, waiting for io.Closer
+	// Close calls and general.DelayCloser complete notifications, when RunContext's ctx has no
+	// deadline.  The default is 20 seconds.  Once it runs out the closer still running is logged
+	// and closing stops with ErrCloseTimeout (or ErrDelayCloserTimeout).
+	SetCloseTimeout(timeout time.Duration)
+
+	// SetCloserTimeout limits how long each io.Closer's Close may take.  One that takes longer
+	// is recorded as ErrCloserTimeout and closing moves on to the next closer, leaving it to
+	// finish on its own.  Zero, the default, only limits closing as a whole, see SetCloseTimeout.
+	SetCloserTimeout(timeout time.Duration)
+
+	// SetShutdownGrace limits how long RunContext waits for Main.Run to return once its ctx is
+	// done.  When the grace runs out ErrShutdownGraceTimeout is returned and the close sequence
+	// starts even though Main.Run is still running.  Zero, the default, waits for Main.Run as
+	// long as it takes.
+	SetShutdownGrace(grace time.Duration)
+
+	// OnStarted registers a callback that is called once building succeeded and Main.Run has
+	// been started, while it is still running.  Callbacks are called in registration order, this
+	// is the place to report readiness.
+	OnStarted(callback func())
+
+	// SetStartBeforeMain controls when Starters are started.  true, the default, starts them all
+	// before Main.Run is called, Main is not run if one fails and that error is returned.  For a
+	// server Main that blocks while Starters are background tasks, this means those tasks are
+	// running before it serves.  false starts them in a goroutine while Main runs.  If one fails
+	// the error is logged and, for a ContextMain, the ctx it is run with is canceled so the run
+	// shuts down, a plain Main is left to return on its own.  When Main returns Run waits for
+	// them to be started and the error is returned after any from Main.
+	SetStartBeforeMain(before bool)
+
+	// OnClosed registers a callback that is called once the close sequence is complete with all
+	// the errors that will be returned.  Callbacks are called in registration order, this is a
+	// place for final logging or flushing that must happen last.
+	OnClosed(callback func(errs []error))
+
+	// AddShutdownHook registers a cleanup, not tied to any produced value, that always runs at
+	// the end of the close sequence, even if building failed before anything was made or closing
+	// timed out.  It is for resources acquired outside producers, like a lock file taken before
+	// Run.  Hooks run in reverse registration order before the OnClosed callbacks, their errors
+	// are returned with the others.
+	AddShutdownHook(hook func() error)
+
+	// Timings returns when shutdown was requested, Main.Run returned and closing started and
+	// completed, so a slow shutdown can be put down to Main not returning promptly or to slow
+	// closers.  It should be called once Run has returned.
+	Timings() Timings
+
+	// SetCloseSorter replaces the default close order.  sorter is given the closers in the
+	// default order, by phase then reverse creation order, and the closers are closed in the
+	// order it returns.  This is an escape hatch for teardown the default order does not handle.
+	SetCloseSorter(sorter func(closers []CloserInfo) []CloserInfo)
+
+	// TypeSnapshot returns the types provided so far, once building is done it can be compared
+	// with the snapshot of another build using TypeSnapshot.Diff
+	TypeSnapshot() TypeSnapshot
+
+	// CloseGraph returns a Graphviz dot graph of the values that will be (or were) closed, with
+	// an edge from each to the next one closed.  It is only complete once building is done.
+	CloseGraph() string
+
+	// ExcludeFromClose stops produced values of the type given like (*Store)(nil) or
+	// (*RedisStore)(nil) from being closed, for values whose lifecycle is managed elsewhere such
+	// as a resource shared between Runners.  Closers registered with CloserRegistry are still
+	// closed.
+	ExcludeFromClose(typePtr interface{}) error
+
+	// Inventory returns an entry for each value provided so far, giving the concrete type bound
+	// to each interface and the producer that made it, in the order they were provided.  It is
+	// a debugging aid for finding which implementation Bind or Replace actually left in place.
+	Inventory() []InventoryEntry
+
+	// BuildOnly calls the added producers like Run but does not look for or run a Main.  Instead
+	// the built values can be used through the returned Accessor until its Close method is called.
+	// If building fails whatever was built is closed and only errors are returned.
+	BuildOnly() (Accessor, []error)
+
+	// SetCloseContext sets a shutdown context, separate from the run context, whose deadline is
+	// the budget for the close sequence instead of the close timeout.  Once it is done closing
+	// stops waiting, so an orchestrator's termination grace period can bound closing.
+	SetCloseContext(ctx context.Context)
+
+	// RunContext is like Run but if ctx has a deadline the close sequence uses the time remaining
+	// before it as the budget for general.DelayCloser complete notifications instead of the
+	// default close timeout, unless a close context is set (see SetCloseContext) or ctx is already
+	// done when closing starts, as its deadline then ended the run and the close timeout is used.
+	// This allows closing to fit in a termination grace period.  Producers that depend on
+	// context.Context are given ctx, see FromContext.  A ContextMain is run with ctx, so ctx
+	// being done (like from signal.NotifyContext) is the trigger for shutdown.
+	RunContext(ctx context.Context) []error
+}
+
+// Drainer is implemented by produced values that need to stop taking new work (like a server
+// that stops accepting connections) before anything is closed.  All Drainers are drained, in
+// reverse creation order, before the normal close sequence.  ctx ends when the close budget is
+// used up.
+type Drainer interface {
+	Drain(ctx context.Context) error
+}
+
+// Starter is implemented by produced values that do background work once everything is built,
+// like a consumer that starts polling a queue.  Run calls Start on each Starter in creation
+// order with its ctx, before or along with Main.Run, see Runner.SetStartBeforeMain.  They are
+// stopped by the close sequence like any other value, BuildOnly does not start them.
+type Starter interface {
+	Start(ctx context.Context) error
+}
+
+// ConditionalCloser is implemented by produced values whose close depends on how the run went,
+// like a transaction that commits on success and rolls back on failure.  It is closed in the
+// close sequence like an io.Closer, success is true if building, Main.Run and guards returned
+// no errors (errors from draining and closing do not count).
+type ConditionalCloser interface {
+	Close(success bool) error
+}
+
+// Registration identifies a producer added with Runner.Register
+type Registration struct {
+	runner *runner
+	index  int
+}
+
+// CloserInfo describes a value registered to be closed, see Runner.SetCloseSorter
+type CloserInfo struct {
+	// Closer is the io.Closer, general.DelayCloser or ConditionalCloser
+	Closer interface{}
+	// Index is the order Closer was registered in, starting at zero
+	Index int
+	// Phase is the close phase of Closer, see Runner.AddInPhase
+	Phase int
+}
+
+// ShutdownGuard is implemented by produced values that may need to hold off shutdown while they
+// finish critical work (like flushing a batch).  Once Main.Run returns, and before anything is
+// drained or closed, each guard is asked if it can shutdown.  If not it is asked again after
+// the returned duration, for up to the shutdown grace (or the close timeout if no grace is set).
+type ShutdownGuard interface {
+	CanShutdown() (bool, time.Duration)
+}
+
+// Documented is implemented by produced values that describe what they are and what they do, the
+// description is then part of the Documentation the Runner provides
+type Documented interface {
+	Doc() string
+}
+
 // ErrProducerNil indicates nil was passed to Add
 var ErrProducerNil = errors.New("producer nil")
 
 // ErrProducerNotFunc indicates a non function was passed to Add
 var ErrProducerNotFunc = errors.New("producer not function")
 
-// ErrProducerInvalidReturns indicates a function returning invalid values was passed to Add
-var ErrProducerInvalidReturns = errors.New("producer may only return interfaces and an optional error")
+// ErrProducerInvalidReturns indicates a function returning a type that can not be provided was
+// passed to Add, see Run for what a producer may return
+var ErrProducerInvalidReturns = errors.New("producer returns a type that can not be provided")
+
+// ErrProducerInvalidInputs indicates a function with a parameter that can not be provided was
+// passed to Add, see Run for what a producer may depend on
+var ErrProducerInvalidInputs = errors.New("producer parameter type can not be provided")
+
+// ErrProducerVariadicInvalid indicates a variadic function whose element type is not an interface
+// was passed to Add, it will be wrapped so the function name and element type can be included
+var ErrProducerVariadicInvalid = errors.New("producer variadic element must be interface")
+
+// ErrFactoryInvalid indicates a function passed to AddFactory does not return exactly one
+// interface and an optional error
+var ErrFactoryInvalid = errors.New("factory producer must return one interface and an optional error")
+
+// ErrFactoryConflict indicates AddFactory was called twice for the same type, it will be wrapped
+// so the type can be included
+var ErrFactoryConflict = errors.New("factory already added")
+
+// ErrBindNotImplemented indicates Bind was given a concrete type that does not implement the
+// interface, it will be wrapped so the types can be included
+var ErrBindNotImplemented = errors.New("bound concrete type does not implement interface")
+
+// ErrBindConflict indicates Bind was called for an interface already bound to a different type,
+// it will be wrapped so the types can be included
+var ErrBindConflict = errors.New("interface already bound")
+
+// ErrSeedInvalid indicates Seed was given a value that does not implement the interface, it
+// will be wrapped so the interface and value type can be included
+var ErrSeedInvalid = errors.New("seed value does not implement interface")
+
+// ErrSeedConflict indicates a seeded interface is also seeded again or provided some other way,
+// it will be wrapped so the interface can be included
+var ErrSeedConflict = errors.New("seeded interface also provided")
+
+// ErrBindForInvalid indicates BindFor was given a producer that does not return just the bound
+// interface, it will be wrapped so the interface and producer type can be included
+var ErrBindForInvalid = errors.New("BindFor producer must return the bound interface")
 
-// ErrProducerInvalidInputs indicates a function with invalid inputs was passed to Add
-var ErrProducerInvalidInputs = errors.New("producer inputs must be interface or slice of interfaces")
+// ErrRegistrationInvalid indicates Replace was given a Registration not returned by Register on
+// the same Runner
+var ErrRegistrationInvalid = errors.New("registration not from this runner")
+
+// ErrReplaceAfterRun indicates Replace was called after Run or BuildOnly
+var ErrReplaceAfterRun = errors.New("replace after run")
+
+// ErrReplaceMismatch indicates Replace was given a producer that does not provide the same types
+// as the one it replaces, it will be wrapped so the types can be included
+var ErrReplaceMismatch = errors.New("replacement provides different types")
+
+// ErrKeyedInvalid indicates AddKeyed was given a key that is nil or not comparable, or a producer
+// that does not return a single interface, it will be wrapped so the key or type can be included
+var ErrKeyedInvalid = errors.New("keyed producer needs a comparable key and one interface return")
+
+// ErrKeyedDuplicate indicates two producers were added with AddKeyed for the same key of the same
+// map type, it will be wrapped so the map type and key can be included
+var ErrKeyedDuplicate = errors.New("duplicate key")
+
+// ErrPrimaryConflict indicates AddPrimary was called for a type that already has a primary
+// producer, it will be wrapped so the type can be included
+var ErrPrimaryConflict = errors.New("type already has a primary producer")
+
+// ErrStructInvalid indicates AddFromStruct was not given a pointer to a struct, or in strict mode
+// the struct has an exported field that is not a func, it will be wrapped so the value or field
+// can be included
+var ErrStructInvalid = errors.New("producer struct invalid")
+
+// ErrHolderInvalid indicates ProvideHolder was given a producer that does not return just the
+// held type, it will be wrapped so the type and producer type can be included
+var ErrHolderInvalid = errors.New("holder producer must return the held type")
+
+// ErrGroupType indicates a group value is not of the type it was expected to be, it will be
+// wrapped so the group and types can be included
+var ErrGroupType = errors.New("group value has wrong type")
+
+// ErrGroupNoCommonType indicates the producers passed to AddGroup do not all return a common type
+var ErrGroupNoCommonType = errors.New("group producers have no common return type")
+
+// ErrLazyConflict indicates a type returned by a lazy producer is also returned by another
+// producer, it will be wrapped so the type can be included
+var ErrLazyConflict = errors.New("lazy producer type also produced by another producer")
+
+// ErrExcludeInvalid indicates ExcludeFromClose was given nil instead of a typed nil pointer
+var ErrExcludeInvalid = errors.New("exclude from close needs a typed nil pointer")
+
+// ErrNotInterfacePointer indicates a value that should be a nil pointer to an interface, like
+// (*SomeInterface)(nil), was not
+var ErrNotInterfacePointer = errors.New("expected pointer to interface")
 
 // ErrMissingDependency indicates there is a missing dependency, it will be wrapped so the missing
 // type can be included
@@ -30,20 +517,87 @@ var ErrMissingDependency = errors.New("missing dependency")
 // missing type can be included
 var ErrNoProducerMakes = errors.New("no producer makes")
 
-// ErrProducerReturnedNil indicates a producer returned nil instead of a valid interface
+// ErrProducerReturnedNil indicates a producer returned nil instead of a valid interface, it will
+// be wrapped so the producer function and type can be included
 var ErrProducerReturnedNil = errors.New("producer returned nil value")
 
-// ErrNoMain indicates no Main was provided
+// ErrAsyncInvalid indicates a producer given to AddAsync does not return a single receive only
+// channel of an interface type, it will be wrapped so the producer type can be included
+var ErrAsyncInvalid = errors.New("async producer must return a receive only interface channel")
+
+// ErrProducerTimeout indicates a producer added with AddWithTimeout (or AddAsync) took too long,
+// it will be wrapped so the producer function and timeout can be included
+var ErrProducerTimeout = errors.New("producer timeout")
+
+// ErrInterceptorNoCall indicates the interceptor from SetProducerInterceptor returned without
+// calling the producer, it will be wrapped so the producer function can be included
+var ErrInterceptorNoCall = errors.New("producer interceptor did not call producer")
+
+// ErrBuildPassLimit indicates building took more passes than allowed by SetMaxBuildPasses, most
+// likely because of a bug in how producers were added
+var ErrBuildPassLimit = errors.New("build pass limit exceeded")
+
+// ErrValueLimit indicates producers returned more values than allowed by SetMaxValues, it will be
+// wrapped so the limit can be included
+var ErrValueLimit = errors.New("value limit exceeded")
+
+// ErrDuplicateProducer indicates strict singletons are on and a type depended on as a single
+// value has more than one producer, it will be wrapped so the type can be included
+var ErrDuplicateProducer = errors.New("more than one producer for single value type")
+
+// ErrAbort may be returned (or wrapped) by a producer to stop building without an error, for
+// example a producer that handles --version.  Main is not run, whatever was built is closed and
+// Run returns only errors from closing.  BuildOnly still returns it.
+var ErrAbort = errors.New("build aborted")
+
+// ErrUnused indicates SetFailOnUnused is on and produced types are not used, it will be wrapped
+// so the types can be included
+var ErrUnused = errors.New("produced types not used")
+
+// ErrNoMain indicates no Main was provided, it will be wrapped so the produced types (and any
+// with a Run method that does not match Main) can be included
 var ErrNoMain = errors.New("No Main interface provided")
 
+// ErrMultipleMain indicates no Main was provided and more than one produced value implements
+// Main, it will be wrapped so the types can be included
+var ErrMultipleMain = errors.New("multiple produced values implement Main")
+
+// ErrShutdownGraceTimeout indicates Main.Run did not return within the shutdown grace after the
+// RunContext ctx was done
+var ErrShutdownGraceTimeout = errors.New("timeout waiting for Main.Run to return")
+
+// ErrShutdownGuardTimeout indicates a ShutdownGuard still could not shutdown when the time
+// allowed ran out, it will be wrapped so the guard type can be included
+var ErrShutdownGuardTimeout = errors.New("timeout waiting for ShutdownGuard")
+
+// ErrCloseTimeout indicates an io.Closer had not returned from Close when the close budget ran
+// out, it will be wrapped so the closer type can be included.  Closing stops there, the Close
+// call is left to finish on its own.
+var ErrCloseTimeout = errors.New("timeout waiting for Close to return")
+
+// ErrCloserTimeout indicates an io.Closer took longer than allowed by SetCloserTimeout, it will
+// be wrapped so the closer type can be included
+var ErrCloserTimeout = errors.New("closer timeout")
+
 // ErrDelayCloserTimeout indicates a timeout waiting for general.DelayCloser(s) to complete
 var ErrDelayCloserTimeout = errors.New("timeout before all DelayCloser results")
 
 // Run runs a dependency stack
 //
 // producers must all be functions. These functions may only have interface or slice of interfaces
-// as there parameters and may return any number of interfaces and an optional error as the last
-// return value.
+// as there parameters (a variadic parameter is treated as a slice) and may return any number of
+// interfaces and an optional error as the last return value.  A concrete type may be returned if
+// it implements an interface registered with AddInterfaces (or bound with Bind), it is provided
+// as those interfaces, and a func() error is provided as Main.  A parameter may also be a pointer
+// to a params struct whose fields are all exported interfaces or slices of interfaces, the struct
+// is allocated and its fields filled in as if each were a parameter.  Channels may also be
+// produced and depended on, a parameter of type <-chan T or chan<- T is given a produced chan T if
+// no producer makes the directional type itself.  A producer returning a slice of interfaces adds
+// every element to the slice provided for the element type, along with any other producers of it.
+//
+// Types are matched exactly.  An alias (type Store = storage.Store) is the type it names, so
+// producers and consumers can use either interchangeably, but a defined interface
+// (type Store storage.Store) is a different type and is not provided as the one it copies.
 //
 // Run first calls all producer functions exactly once.  If any producer functions return an error
 // that error will be returned. If the parameters of a producer function can not be produced by
@@ -54,22 +608,30 @@ var ErrDelayCloserTimeout = errors.New("timeout before all DelayCloser results")
 // Run method will be called exactly once. If no Main interface was produced an error will be
 // returned.
 //
-// Finally all produced values that implement io.Closer or general.DelayCloser will have the Close
+// Finally all produced values that implement Drainer have Drain called and then all produced
+// values that implement io.Closer, general.DelayCloser or ConditionalCloser will have the Close
 // method of those interfaces called. This will be done in the opposite order that the values were
-// produced insuring that a values Close will be called before any of its dependencies.
+// produced insuring that a values Close will be called before any of its dependencies.  This
+// holds for values produced as io.Closer (or general.DelayCloser) themselves, a value that was
+// injected, even as an element of a []io.Closer, is still closed exactly once by Run.
 //
 // The error slice returned may have errors from the producer functions or an error from the
 // Main.Run function.  In either case there my also be errors from the Close functions of produced
 // values.
 func Run(producers []interface{}) []error {
-	runner := new()
+	runner := New()
 
 	for _, v := range producers {
-		err := runner.add(v)
+		err := runner.Add(v)
 		if err != nil {
 			return []error{err}
 		}
 	}
 
-	return runner.run()
+	return runner.Run()
+}
+
+// New creates a Runner that producers can be added to before calling its Run method
+func New() Runner {
+	return new()
 }
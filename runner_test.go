@@ -1,9 +1,16 @@
 package runner
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/blbgo/general"
 	"github.com/blbgo/testing/assert"
 )
 
@@ -16,6 +23,20 @@ func TestErrNoMain(t *testing.T) {
 	a.True(errors.Is(errs[0], ErrNoMain))
 }
 
+type testWrongRun struct{ testStruct2 }
+
+func (r testWrongRun) Run() {}
+
+func TestErrNoMainWrongRun(t *testing.T) {
+	a := assert.New(t)
+
+	errs := Run([]interface{}{func() testInterface2 { return testWrongRun{} }})
+	a.Equal(1, len(errs))
+	a.True(errors.Is(errs[0], ErrNoMain))
+	a.ErrorContains(errs[0], "runner.testInterface2")
+	a.ErrorContains(errs[0], "runner.testWrongRun has Run method func()")
+}
+
 //********************
 func TestErrProducerNil(t *testing.T) {
 	a := assert.New(t)
@@ -107,6 +128,7 @@ func TestErrProducerReturnedNil(t *testing.T) {
 		errors.Is(errs[0], ErrProducerReturnedNil),
 		"Expecting", ErrProducerReturnedNil, "got", errs[0],
 	)
+	a.ErrorContains(errs[0], "runner.newNilProvider")
 }
 
 //********************
@@ -186,3 +208,2678 @@ func TestNeedSliceWhenNoneNOTError(t *testing.T) {
 	errs := Run([]interface{}{new1ConsumeSice2, newMain})
 	a.Equal(0, len(errs))
 }
+
+//********************
+func TestAddGroupSingleConsumerError(t *testing.T) {
+	a := assert.New(t)
+
+	r := New()
+	a.NoError(r.AddGroup(new2))
+	a.NoError(r.Add(new1Consume2))
+	errs := r.Run()
+	a.Equal(1, len(errs))
+	a.True(errors.Is(errs[0], ErrNoProducerMakes), "Expecting", ErrNoProducerMakes, "got", errs[0])
+}
+
+func TestAddGroupSliceConsumer(t *testing.T) {
+	a := assert.New(t)
+
+	r := New()
+	a.NoError(r.AddGroup(new2))
+	a.NoError(r.Add(new1ConsumeSice2))
+	a.NoError(r.Add(newMain))
+	errs := r.Run()
+	a.Equal(0, len(errs))
+}
+
+func TestErrGroupNoCommonType(t *testing.T) {
+	a := assert.New(t)
+
+	r := New()
+	err := r.AddGroup(new2, new1Consume2)
+	a.True(errors.Is(err, ErrGroupNoCommonType), "Expecting", ErrGroupNoCommonType, "got", err)
+}
+
+//********************
+func new1ConsumeVariadic2(i ...testInterface2) testInterface1 {
+	if len(i) != 2 {
+		return nil
+	}
+	return testStruct1{}
+}
+
+func TestVariadicProducer(t *testing.T) {
+	a := assert.New(t)
+
+	errs := Run([]interface{}{new1ConsumeVariadic2, new2, new2, newMain})
+	a.Equal(0, len(errs), errs)
+}
+
+func new1ConsumeVariadicInt(i ...int) testInterface1 { return testStruct1{} }
+
+func TestErrProducerVariadicInvalid(t *testing.T) {
+	a := assert.New(t)
+
+	errs := Run([]interface{}{new1ConsumeVariadicInt})
+	a.Equal(1, len(errs))
+	a.True(
+		errors.Is(errs[0], ErrProducerVariadicInvalid),
+		"Expecting", ErrProducerVariadicInvalid, "got", errs[0],
+	)
+	a.ErrorContains(errs[0], "new1ConsumeVariadicInt")
+}
+
+//********************
+type testStruct2StuckDelayCloser struct{}
+
+func (r testStruct2StuckDelayCloser) Method() string { return "testStruct2StuckDelayCloser.Method" }
+
+func (r testStruct2StuckDelayCloser) Close(doneChan chan<- error) {}
+
+func new2StuckDelayCloser() testInterface2 { return testStruct2StuckDelayCloser{} }
+
+func TestRunContextDeadlineBoundsClose(t *testing.T) {
+	a := assert.New(t)
+
+	r := New()
+	a.NoError(r.Add(new1ConsumeSice2))
+	a.NoError(r.Add(new2StuckDelayCloser))
+	a.NoError(r.Add(newMain))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	errs := r.RunContext(ctx)
+	a.True(time.Since(start) < defaultCloseTimeout, "close did not use context deadline")
+	a.Equal(1, len(errs))
+	a.True(
+		errors.Is(errs[0], ErrDelayCloserTimeout),
+		"Expecting", ErrDelayCloserTimeout, "got", errs[0],
+	)
+}
+
+type testSlowDelayCloser struct {
+	testStruct2
+}
+
+func (r testSlowDelayCloser) Close(doneChan chan<- error) {
+	go func() {
+		time.Sleep(time.Millisecond)
+		doneChan <- nil
+	}()
+}
+
+func TestRunContextDeadlineEndsRun(t *testing.T) {
+	a := assert.New(t)
+
+	// the deadline ended the run so it can not also be the close budget
+	r := New()
+	a.NoError(r.Add(func() testInterface2 { return testSlowDelayCloser{} }))
+	a.NoError(r.Add(func(i testInterface2) ContextMain { return testContextMain{} }))
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	errs := r.RunContext(ctx)
+	a.Equal(0, len(errs), errs)
+
+	// a close context bounds closing instead
+	r = New()
+	a.NoError(r.Add(new2StuckDelayCloser))
+	a.NoError(r.Add(func(i testInterface2) ContextMain { return testContextMain{} }))
+	closeCtx, closeCancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer closeCancel()
+	r.SetCloseContext(closeCtx)
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	errs = r.RunContext(ctx)
+	a.True(time.Since(start) < defaultCloseTimeout, "close did not use close context deadline")
+	a.Equal(1, len(errs), errs)
+	a.True(errors.Is(errs[0], ErrDelayCloserTimeout), errs)
+}
+
+func TestCloseContextCanceledStopsClose(t *testing.T) {
+	a := assert.New(t)
+
+	// a close context without a deadline stops closing once it is canceled
+	r := New()
+	r.SetCloseTimeout(time.Minute)
+	a.NoError(r.Add(new2StuckDelayCloser))
+	a.NoError(r.Add(func(i testInterface2) ContextMain { return testContextMain{} }))
+	closeCtx, closeCancel := context.WithCancel(context.Background())
+	r.SetCloseContext(closeCtx)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		closeCancel()
+	}()
+	start := time.Now()
+	errs := r.RunContext(ctx)
+	a.True(time.Since(start) < time.Second, "close did not stop when the close context was done")
+	a.Equal(1, len(errs), errs)
+	a.True(errors.Is(errs[0], ErrDelayCloserTimeout), errs)
+}
+
+//********************
+type lazyCounter struct{ calls int }
+
+func (r *lazyCounter) new2() testInterface2 {
+	r.calls++
+	return testStruct2{}
+}
+
+type testInterface3 interface{ Method3() string }
+type testStruct3 struct{}
+
+func (r testStruct3) Method3() string { return "testStruct3.Method3" }
+
+func new3Consume2(i testInterface2) testInterface3 { return testStruct3{} }
+
+func newMainConsume13(i1 testInterface1, i3 testInterface3) Main { return testMain{} }
+
+func TestAddLazyNotCalledWhenUnused(t *testing.T) {
+	a := assert.New(t)
+
+	counter := &lazyCounter{}
+	r := New()
+	a.NoError(r.AddLazy(counter.new2))
+	a.NoError(r.Add(newMainError))
+	errs := r.Run()
+	a.Equal(1, len(errs))
+	a.Equal(0, counter.calls)
+}
+
+func TestAddLazyCalledOnce(t *testing.T) {
+	a := assert.New(t)
+
+	counter := &lazyCounter{}
+	r := New()
+	a.NoError(r.Add(newMainConsume13))
+	a.NoError(r.Add(new1Consume2))
+	a.NoError(r.Add(new3Consume2))
+	a.NoError(r.AddLazy(counter.new2))
+	errs := r.Run()
+	a.Equal(0, len(errs), errs)
+	a.Equal(1, counter.calls)
+}
+
+func TestErrLazyConflict(t *testing.T) {
+	a := assert.New(t)
+
+	r := New()
+	a.NoError(r.Add(new2))
+	err := r.AddLazy(new2)
+	a.True(errors.Is(err, ErrLazyConflict), "Expecting", ErrLazyConflict, "got", err)
+}
+
+//********************
+type countingCloser struct{ closes *int }
+
+func (r countingCloser) Close() error {
+	*r.closes++
+	return nil
+}
+
+type closerCollector struct{ closers []io.Closer }
+
+func (r closerCollector) Method() string { return "closerCollector.Method" }
+
+func TestCloserCollectedAndClosedOnce(t *testing.T) {
+	a := assert.New(t)
+
+	closes := 0
+	var collected []io.Closer
+	newCloser := func() io.Closer { return countingCloser{closes: &closes} }
+	newCollector := func(closers []io.Closer) testInterface1 {
+		collected = closers
+		return closerCollector{closers: closers}
+	}
+	errs := Run([]interface{}{newCloser, newCloser, newCollector, newMain})
+	a.Equal(0, len(errs), errs)
+	a.Equal(2, len(collected))
+	a.Equal(2, closes)
+}
+
+//********************
+func TestBuildOnly(t *testing.T) {
+	a := assert.New(t)
+
+	r := New()
+	a.NoError(r.Add(new1ConsumeSice2))
+	a.NoError(r.Add(new2Closer))
+	a.NoError(r.AddLazy(new3Consume1))
+	accessor, errs := r.BuildOnly()
+	a.Equal(0, len(errs), errs)
+
+	i1, err := Get[testInterface1](accessor)
+	a.NoError(err)
+	a.Equal("testStruct1.Method", i1.Method())
+
+	i3, err := Get[testInterface3](accessor)
+	a.NoError(err)
+	a.Equal("testStruct3.Method3", i3.Method3())
+
+	_, err = Get[Main](accessor)
+	a.True(errors.Is(err, ErrNoProducerMakes), "Expecting", ErrNoProducerMakes, "got", err)
+
+	errs = accessor.Close()
+	a.Equal(1, len(errs))
+	a.True(errors.Is(errs[0], errCloser), "Expecting", errCloser, "got", errs[0])
+}
+
+func new3Consume1(i testInterface1) testInterface3 { return testStruct3{} }
+
+//********************
+type testConcrete struct{ closes *int }
+
+func (r *testConcrete) Method() string  { return "testConcrete.Method" }
+func (r *testConcrete) Method3() string { return "testConcrete.Method3" }
+func (r *testConcrete) Close() error    { *r.closes++; return nil }
+
+func TestAddInterfacesConcreteReturn(t *testing.T) {
+	a := assert.New(t)
+
+	closes := 0
+	newConcrete := func() *testConcrete { return &testConcrete{closes: &closes} }
+	r := New()
+	a.NoError(r.AddInterfaces((*testInterface1)(nil), (*testInterface3)(nil)))
+	a.NoError(r.Add(newConcrete))
+	a.NoError(r.Add(newMainConsume13))
+	errs := r.Run()
+	a.Equal(0, len(errs), errs)
+	a.Equal(1, closes)
+}
+
+type testInterface4 interface{ Method4() string }
+
+func TestAddInterfacesErrors(t *testing.T) {
+	a := assert.New(t)
+
+	r := New()
+	err := r.AddInterfaces(testStruct1{})
+	a.True(errors.Is(err, ErrNotInterfacePointer), "Expecting", ErrNotInterfacePointer, "got", err)
+
+	a.NoError(r.AddInterfaces((*testInterface4)(nil)))
+	err = r.Add(func() *testConcrete { return nil })
+	a.True(
+		errors.Is(err, ErrProducerInvalidReturns),
+		"Expecting", ErrProducerInvalidReturns, "got", err,
+	)
+}
+
+//********************
+func newTypedNilProvider() testInterface1 {
+	var nilPtr *testConcrete
+	return nilPtr
+}
+
+func TestErrProducerReturnedTypedNil(t *testing.T) {
+	a := assert.New(t)
+
+	errs := Run([]interface{}{newTypedNilProvider})
+	a.Equal(1, len(errs))
+	a.True(
+		errors.Is(errs[0], ErrProducerReturnedNil),
+		"Expecting", ErrProducerReturnedNil, "got", errs[0],
+	)
+}
+
+//********************
+type testLogger struct{ lines []string }
+
+func (r *testLogger) Log(v ...interface{}) error {
+	r.lines = append(r.lines, fmt.Sprint(v...))
+	return nil
+}
+
+func (r *testLogger) Logf(format string, v ...interface{}) error {
+	r.lines = append(r.lines, fmt.Sprintf(format, v...))
+	return nil
+}
+
+var errBestEffort = errors.New("best effort failed")
+
+func new2Fails() (testInterface2, error) { return nil, errBestEffort }
+
+func TestAddBestEffortFailureSkipped(t *testing.T) {
+	a := assert.New(t)
+
+	logger := &testLogger{}
+	var received []testInterface2
+	r := New()
+	r.SetLogger(logger)
+	a.NoError(r.AddBestEffort(new2Fails))
+	a.NoError(r.Add(func(i []testInterface2) testInterface1 {
+		received = i
+		return testStruct1{}
+	}))
+	a.NoError(r.Add(newMain))
+	errs := r.Run()
+	a.Equal(0, len(errs), errs)
+	a.Equal(0, len(received))
+	a.Equal(1, len(logger.lines))
+}
+
+//********************
+var errCustomExit = errors.New("custom exit")
+
+func TestExitCode(t *testing.T) {
+	a := assert.New(t)
+
+	a.Equal(ExitCodeOK, ExitCode(nil))
+	a.Equal(ExitCodeWiring, ExitCode(Run(nil)))
+	a.Equal(ExitCodeError, ExitCode(Run([]interface{}{newMainError})))
+	a.Equal(ExitCodeSignal, ExitCode([]error{fmt.Errorf("wrapped %w", ErrShutdownSignal)}))
+
+	RegisterExitCode(errCustomExit, 7)
+	a.Equal(7, ExitCode([]error{errCustomExit, errMainError}))
+}
+
+//********************
+type testMainTypeInfo struct{ TypeInfo }
+
+func (r testMainTypeInfo) Run() error {
+	if len(r.Types()) != 2 {
+		return fmt.Errorf("unexpected types %v", r.Types())
+	}
+	return nil
+}
+
+func TestTypeInfo(t *testing.T) {
+	a := assert.New(t)
+
+	newMainTypeInfo := func(i testInterface1, info TypeInfo) Main { return testMainTypeInfo{info} }
+	errs := Run([]interface{}{new1ConsumeSice2, newMainTypeInfo})
+	a.Equal(0, len(errs), errs)
+}
+
+//********************
+var new2Symbol = new2
+
+func TestAddSymbol(t *testing.T) {
+	a := assert.New(t)
+
+	r := New()
+	a.NoError(r.AddSymbol(&new2Symbol))
+	a.NoError(r.AddSymbol(new1Consume2))
+	err := r.AddSymbol(&errCloser)
+	a.True(errors.Is(err, ErrProducerNotFunc), "Expecting", ErrProducerNotFunc, "got", err)
+}
+
+//********************
+func TestCallOrder(t *testing.T) {
+	a := assert.New(t)
+
+	r := New()
+	a.NoError(r.Add(newMain))
+	a.NoError(r.Add(new1Consume2))
+	a.NoError(r.Add(new2))
+	errs := r.Run()
+	a.Equal(0, len(errs), errs)
+	order := r.CallOrder()
+	a.Equal(3, len(order))
+	a.True(strings.HasSuffix(order[0], ".new2"), order)
+	a.True(strings.HasSuffix(order[1], ".new1Consume2"), order)
+	a.True(strings.HasSuffix(order[2], ".newMain"), order)
+}
+
+func TestAddBestEffortSliceElementOmitted(t *testing.T) {
+	a := assert.New(t)
+
+	var received []testInterface2
+	r := New()
+	a.NoError(r.AddBestEffort(new2Fails))
+	a.NoError(r.Add(new2))
+	a.NoError(r.Add(func(i []testInterface2) testInterface1 {
+		received = i
+		return testStruct1{}
+	}))
+	a.NoError(r.Add(newMain))
+	errs := r.Run()
+	a.Equal(0, len(errs), errs)
+	a.Equal(1, len(received))
+}
+
+func TestAddBestEffortStillSliceWhenOneRemains(t *testing.T) {
+	a := assert.New(t)
+
+	r := New()
+	a.NoError(r.AddBestEffort(new2Fails))
+	a.NoError(r.Add(new2))
+	a.NoError(r.Add(new1Consume2))
+	errs := r.Run()
+	a.Equal(1, len(errs))
+	a.True(errors.Is(errs[0], ErrNoProducerMakes), "Expecting", ErrNoProducerMakes, "got", errs[0])
+}
+
+//********************
+func new1UsingContext(ctx context.Context, i2 testInterface2) (testInterface1, error) {
+	if ctx.Value(testContextKey{}) != "value" {
+		return nil, errors.New("RunContext context not provided")
+	}
+	i2again, err := Get[testInterface2](FromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if i2again != i2 {
+		return nil, errors.New("FromContext returned a different value")
+	}
+	return testStruct1{}, nil
+}
+
+type testContextKey struct{}
+
+func TestFromContext(t *testing.T) {
+	a := assert.New(t)
+
+	r := New()
+	a.NoError(r.Add(new1UsingContext))
+	a.NoError(r.Add(new2))
+	a.NoError(r.Add(newMain))
+	errs := r.RunContext(context.WithValue(context.Background(), testContextKey{}, "value"))
+	a.Equal(0, len(errs), errs)
+	a.Nil(FromContext(context.Background()))
+}
+
+//********************
+func TestBuildPasses(t *testing.T) {
+	a := assert.New(t)
+
+	r := New()
+	a.NoError(r.Add(newMain))
+	a.NoError(r.Add(new1Consume2))
+	a.NoError(r.Add(new2))
+	errs := r.Run()
+	a.Equal(0, len(errs), errs)
+	a.Equal(3, r.BuildPasses())
+
+	r = New()
+	r.SetMaxBuildPasses(2)
+	a.NoError(r.Add(newMain))
+	a.NoError(r.Add(new1Consume2))
+	a.NoError(r.Add(new2))
+	errs = r.Run()
+	a.Equal(1, len(errs))
+	a.True(errors.Is(errs[0], ErrBuildPassLimit), "Expecting", ErrBuildPassLimit, "got", errs[0])
+}
+
+//********************
+type testParams struct {
+	I2     testInterface2
+	Slice3 []testInterface3
+}
+
+func new1ConsumeParams(params *testParams) testInterface1 {
+	if params.I2 == nil || params.Slice3 == nil || len(params.Slice3) != 0 {
+		return nil
+	}
+	return testStruct1{}
+}
+
+type testBadParams struct {
+	i2 testInterface2
+}
+
+func new1ConsumeBadParams(params *testBadParams) testInterface1 { return testStruct1{} }
+
+func TestParamsStruct(t *testing.T) {
+	a := assert.New(t)
+
+	errs := Run([]interface{}{new1ConsumeParams, new2, newMain})
+	a.Equal(0, len(errs), errs)
+
+	errs = Run([]interface{}{new1ConsumeBadParams})
+	a.Equal(1, len(errs))
+	a.True(
+		errors.Is(errs[0], ErrProducerInvalidInputs),
+		"Expecting", ErrProducerInvalidInputs, "got", errs[0],
+	)
+}
+
+//********************
+type testServiceMain struct{}
+
+func (r testServiceMain) Method3() string { return "testServiceMain.Method3" }
+func (r testServiceMain) Run() error      { return errMainError }
+
+func new3ServiceMain() testInterface3 { return testServiceMain{} }
+
+type testService1Main struct{ testMain }
+
+func (r testService1Main) Method() string { return "testService1Main.Method" }
+
+func TestServiceIsMain(t *testing.T) {
+	a := assert.New(t)
+
+	errs := Run([]interface{}{new3ServiceMain})
+	a.Equal(1, len(errs))
+	a.True(errors.Is(errs[0], errMainError), "Expecting", errMainError, "got", errs[0])
+}
+
+func TestErrMultipleMain(t *testing.T) {
+	a := assert.New(t)
+
+	new1ServiceMain := func() testInterface1 { return testService1Main{} }
+	errs := Run([]interface{}{new3ServiceMain, new1ServiceMain})
+	a.Equal(1, len(errs))
+	a.True(errors.Is(errs[0], ErrMultipleMain), "Expecting", ErrMultipleMain, "got", errs[0])
+}
+
+//********************
+type testDrainCloser struct {
+	name   string
+	events *[]string
+}
+
+func (r testDrainCloser) Method3() string { return r.name }
+
+func (r testDrainCloser) Drain(ctx context.Context) error {
+	if _, ok := ctx.Deadline(); !ok {
+		return errors.New("drain context has no deadline")
+	}
+	*r.events = append(*r.events, "drain "+r.name)
+	return nil
+}
+
+func (r testDrainCloser) Close() error {
+	*r.events = append(*r.events, "close "+r.name)
+	return nil
+}
+
+func TestDrainBeforeClose(t *testing.T) {
+	a := assert.New(t)
+
+	var events []string
+	newA := func() testInterface3 { return testDrainCloser{name: "a", events: &events} }
+	newB := func(i testInterface3) testInterface1 {
+		return testStruct1{}
+	}
+	newC := func(i testInterface1) io.Closer { return testDrainCloser{name: "c", events: &events} }
+	errs := Run([]interface{}{newA, newB, newC, newMain})
+	a.Equal(0, len(errs), errs)
+	a.Equal("drain c,drain a,close c,close a", strings.Join(events, ","))
+}
+
+//********************
+func TestAddAll(t *testing.T) {
+	a := assert.New(t)
+
+	r := New()
+	errs := r.AddAll(new1Consume2, nil, new2, 2, newMain)
+	a.Equal(2, len(errs))
+	a.True(errors.Is(errs[0], ErrProducerNil), "Expecting", ErrProducerNil, "got", errs[0])
+	a.True(errors.Is(errs[1], ErrProducerNotFunc), "Expecting", ErrProducerNotFunc, "got", errs[1])
+	errs = r.Run()
+	a.Equal(0, len(errs), errs)
+}
+
+//********************
+type testEvent interface{ Name() string }
+
+func TestChannelDependency(t *testing.T) {
+	a := assert.New(t)
+
+	events := make(chan testEvent, 1)
+	var received <-chan testEvent
+	var sent chan<- testEvent
+	newEvents := func() chan testEvent { return events }
+	newReceiver := func(c <-chan testEvent) testInterface1 {
+		received = c
+		return testStruct1{}
+	}
+	newSender := func(c chan<- testEvent) testInterface3 {
+		sent = c
+		return testStruct3{}
+	}
+	errs := Run([]interface{}{newReceiver, newSender, newEvents, newMainConsume13})
+	a.Equal(0, len(errs), errs)
+	a.True(received == events, "receive channel is not the produced channel")
+	a.True(sent == events, "send channel is not the produced channel")
+}
+
+//********************
+func TestOnClosed(t *testing.T) {
+	a := assert.New(t)
+
+	var calls []string
+	r := New()
+	a.NoError(r.Add(new2Closer))
+	a.NoError(r.Add(new1ConsumeSice2))
+	a.NoError(r.Add(newMain))
+	r.OnClosed(func(errs []error) { calls = append(calls, fmt.Sprint("first ", len(errs))) })
+	r.OnClosed(func(errs []error) { calls = append(calls, fmt.Sprint("second ", len(errs))) })
+	errs := r.Run()
+	a.Equal(1, len(errs))
+	a.Equal("first 1,second 1", strings.Join(calls, ","))
+}
+
+//********************
+func TestSliceConsumerWaitsForAllProducers(t *testing.T) {
+	a := assert.New(t)
+
+	var received []testInterface2
+	newConsumer := func(i []testInterface2) testInterface1 {
+		received = i
+		return testStruct1{}
+	}
+	// the last producer of testInterface2 can only run once testInterface3 exists, which is
+	// itself added last
+	new2Consume3 := func(i testInterface3) testInterface2 { return testStruct2{} }
+	new3 := func() testInterface3 { return testStruct3{} }
+	errs := Run([]interface{}{newConsumer, new2, new2Consume3, new2Closer, newMain, new3})
+	a.Equal(1, len(errs))
+	a.True(errors.Is(errs[0], errCloser), "Expecting", errCloser, "got", errs[0])
+	a.Equal(3, len(received))
+}
+
+//********************
+func TestCloseGraph(t *testing.T) {
+	a := assert.New(t)
+
+	r := New()
+	a.NoError(r.Add(new2Closer))
+	a.NoError(r.Add(new2DelayCloser))
+	a.NoError(r.Add(new1ConsumeSice2))
+	_, errs := r.BuildOnly()
+	a.Equal(0, len(errs), errs)
+	a.Equal(
+		"digraph close {\n"+
+			"\tc1 [label=\"runner.testStruct2DelayCloser\"];\n"+
+			"\tc0 [label=\"runner.testStruct2Closer\"];\n"+
+			"\tc1 -> c0;\n"+
+			"}\n",
+		r.CloseGraph(),
+	)
+}
+
+//********************
+func TestStrictSingletons(t *testing.T) {
+	a := assert.New(t)
+
+	r := New()
+	r.SetStrictSingletons(true)
+	a.NoError(r.Add(newMain))
+	a.NoError(r.Add(new1Consume2))
+	a.NoError(r.Add(new2))
+	a.NoError(r.Add(new2Closer))
+	errs := r.Run()
+	a.Equal(1, len(errs), errs)
+	a.True(errors.Is(errs[0], ErrDuplicateProducer), errs[0])
+	a.ErrorContains(errs[0], "testInterface2")
+	a.Equal(0, len(r.CallOrder()))
+}
+
+func TestStrictSingletonsAllowsSlices(t *testing.T) {
+	a := assert.New(t)
+
+	r := New()
+	r.SetStrictSingletons(true)
+	a.NoError(r.Add(newMain))
+	a.NoError(r.Add(new1ConsumeSice2))
+	a.NoError(r.Add(new2))
+	a.NoError(r.Add(func() testInterface2 { return testStruct2{} }))
+	errs := r.Run()
+	a.Equal(0, len(errs), errs)
+}
+
+//********************
+func discover[T any](found ...T) func() []T {
+	return func() []T { return found }
+}
+
+func TestProducerReturnsSlice(t *testing.T) {
+	a := assert.New(t)
+
+	var got []testInterface2
+	r := New()
+	a.NoError(r.Add(discover[testInterface2](testStruct2{}, testStruct2DelayCloser{})))
+	a.NoError(r.Add(new2))
+	a.NoError(r.Add(discover[testInterface2]()))
+	a.NoError(r.Add(func(i []testInterface2) Main {
+		got = i
+		return testMain{}
+	}))
+	errs := r.Run()
+	a.Equal(1, len(errs), errs)
+	a.True(errors.Is(errs[0], errDelayCloser), errs[0])
+	a.Equal(3, len(got))
+}
+
+func TestProducerReturnsSliceNilElement(t *testing.T) {
+	a := assert.New(t)
+
+	r := New()
+	a.NoError(r.Add(discover[testInterface2](testStruct2{}, nil)))
+	a.NoError(r.Add(new1ConsumeSice2))
+	a.NoError(r.Add(newMain))
+	errs := r.Run()
+	a.Equal(1, len(errs), errs)
+	a.True(errors.Is(errs[0], ErrProducerReturnedNil), errs[0])
+}
+
+func TestAddLazySliceInvalid(t *testing.T) {
+	a := assert.New(t)
+
+	r := New()
+	a.True(errors.Is(r.AddLazy(discover[testInterface2]()), ErrProducerInvalidReturns))
+}
+
+//********************
+type testBlockingMain struct{ release chan struct{} }
+
+func (r testBlockingMain) Run() error {
+	<-r.release
+	return nil
+}
+
+func TestShutdownGraceTimeout(t *testing.T) {
+	a := assert.New(t)
+
+	release := make(chan struct{})
+	defer close(release)
+	r := New()
+	r.SetShutdownGrace(10 * time.Millisecond)
+	a.NoError(r.Add(func() Main { return testBlockingMain{release: release} }))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	errs := r.RunContext(ctx)
+	a.Equal(1, len(errs), errs)
+	a.True(errors.Is(errs[0], ErrShutdownGraceTimeout), errs[0])
+}
+
+func TestShutdownGraceMainReturns(t *testing.T) {
+	a := assert.New(t)
+
+	release := make(chan struct{})
+	r := New()
+	r.SetShutdownGrace(time.Second)
+	a.NoError(r.Add(func() Main { return testBlockingMain{release: release} }))
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		cancel()
+		close(release)
+	}()
+	errs := r.RunContext(ctx)
+	a.Equal(0, len(errs), errs)
+}
+
+func TestShutdownGraceTimeoutMainKeepsValues(t *testing.T) {
+	a := assert.New(t)
+
+	done := make(chan []error, 1)
+	r := New()
+	r.SetShutdownGrace(10 * time.Millisecond)
+	a.NoError(r.Add(new2))
+	a.NoError(r.Add(func(i testInterface2, children ChildRunners) Main {
+		return testMainFunc(func() error {
+			// ignore the shutdown past the grace then use the parent values
+			time.Sleep(50 * time.Millisecond)
+			child := children.Child()
+			if err := child.Add(new1Consume2); err != nil {
+				done <- []error{err}
+				return nil
+			}
+			_, errs := child.BuildOnly()
+			done <- errs
+			return nil
+		})
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	errs := r.RunContext(ctx)
+	a.Equal(1, len(errs), errs)
+	a.True(errors.Is(errs[0], ErrShutdownGraceTimeout), errs[0])
+	childErrs := <-done
+	a.Equal(0, len(childErrs), childErrs)
+}
+
+//********************
+type testOrderCloser struct {
+	testStruct2
+	name  string
+	order *[]string
+}
+
+func (r testOrderCloser) Close() error {
+	*r.order = append(*r.order, r.name)
+	return nil
+}
+
+func TestCloserRegistry(t *testing.T) {
+	a := assert.New(t)
+
+	var order []string
+	r := New()
+	a.NoError(r.Add(func(registry CloserRegistry) testInterface2 {
+		registry.Add(testOrderCloser{name: "internal1", order: &order})
+		registry.Add(testOrderCloser{name: "internal2", order: &order})
+		return testOrderCloser{name: "returned", order: &order}
+	}))
+	a.NoError(r.Add(new1Consume2))
+	a.NoError(r.Add(newMain))
+	errs := r.Run()
+	a.Equal(0, len(errs), errs)
+	a.Equal("returned internal2 internal1", strings.Join(order, " "))
+}
+
+//********************
+func TestFailures(t *testing.T) {
+	a := assert.New(t)
+
+	var failure, other error
+	var all []error
+	r := New()
+	a.NoError(r.AddBestEffort(new2Fails))
+	a.NoError(r.Add(func(i []testInterface2, f Failures) Main {
+		failure = f.Failure((*testInterface2)(nil))
+		other = f.Failure((*testInterface1)(nil))
+		return testMainFunc(func() error {
+			all = f.Errors()
+			return nil
+		})
+	}))
+	errs := r.Run()
+	a.Equal(0, len(errs), errs)
+	a.Equal(1, len(all))
+	a.Equal(failure, all[0])
+	a.True(errors.Is(failure, errBestEffort), failure)
+	a.ErrorContains(failure, "new2Fails")
+	a.Nil(other)
+}
+
+//********************
+func TestOnStarted(t *testing.T) {
+	a := assert.New(t)
+
+	release := make(chan struct{})
+	var started []int
+	r := New()
+	a.NoError(r.Add(func() Main { return testBlockingMain{release: release} }))
+	r.OnStarted(func() { started = append(started, 1) })
+	r.OnStarted(func() {
+		started = append(started, 2)
+		close(release)
+	})
+	errs := r.Run()
+	a.Equal(0, len(errs), errs)
+	a.Equal("[1 2]", fmt.Sprint(started))
+}
+
+func TestOnStartedNotCalledOnBuildError(t *testing.T) {
+	a := assert.New(t)
+
+	called := false
+	r := New()
+	a.NoError(r.Add(newMain))
+	r.OnStarted(func() { called = true })
+	errs := r.Run()
+	a.Equal(1, len(errs), errs)
+	a.False(called)
+}
+
+//********************
+type testOrderCloser1 struct {
+	testStruct1
+	testOrderCloser
+}
+
+func TestPartialBuildTeardown(t *testing.T) {
+	a := assert.New(t)
+
+	var order []string
+	newCloser := func(name string) func() testInterface2 {
+		return func() testInterface2 { return testOrderCloser{name: name, order: &order} }
+	}
+	errFailed := errors.New("failed")
+	r := New()
+	for _, name := range []string{"c1", "c2", "c3", "c4", "c5"} {
+		a.NoError(r.Add(newCloser(name)))
+	}
+	a.NoError(r.Add(func(i []testInterface2) (testInterface1, error) {
+		return testOrderCloser1{testOrderCloser: testOrderCloser{name: "partial", order: &order}},
+			errFailed
+	}))
+	a.NoError(r.Add(func(i testInterface1) testInterface3 {
+		order = append(order, "not called")
+		return testStruct3{}
+	}))
+	a.NoError(r.Add(newMainConsume13))
+	errs := r.Run()
+	a.Equal(1, len(errs), errs)
+	a.True(errors.Is(errs[0], errFailed), errs[0])
+	a.Equal("partial c5 c4 c3 c2 c1", strings.Join(order, " "))
+}
+
+//********************
+type testGuard struct {
+	testStruct2
+	notYet *int
+}
+
+func (r testGuard) CanShutdown() (bool, time.Duration) {
+	if *r.notYet == 0 {
+		return true, 0
+	}
+	*r.notYet--
+	return false, time.Millisecond
+}
+
+func TestShutdownGuard(t *testing.T) {
+	a := assert.New(t)
+
+	notYet := 3
+	r := New()
+	a.NoError(r.Add(func() testInterface2 { return testGuard{notYet: &notYet} }))
+	a.NoError(r.Add(new1Consume2))
+	a.NoError(r.Add(newMain))
+	errs := r.Run()
+	a.Equal(0, len(errs), errs)
+	a.Equal(0, notYet)
+}
+
+func TestShutdownGuardTimeout(t *testing.T) {
+	a := assert.New(t)
+
+	notYet := 1000000
+	r := New()
+	r.SetShutdownGrace(10 * time.Millisecond)
+	a.NoError(r.Add(func() testInterface2 { return testGuard{notYet: &notYet} }))
+	a.NoError(r.Add(new1Consume2))
+	a.NoError(r.Add(newMain))
+	errs := r.Run()
+	a.Equal(1, len(errs), errs)
+	a.True(errors.Is(errs[0], ErrShutdownGuardTimeout), errs[0])
+	a.ErrorContains(errs[0], "runner.testGuard")
+}
+
+//********************
+type testMainFunc func() error
+
+func (r testMainFunc) Run() error { return r() }
+
+func TestAddFactory(t *testing.T) {
+	a := assert.New(t)
+
+	var order []string
+	made := 0
+	r := New()
+	a.NoError(r.AddFactory(func(i testInterface1) testInterface2 {
+		made++
+		return testOrderCloser{name: fmt.Sprint("made", made), order: &order}
+	}))
+	a.NoError(r.Add(func() testInterface1 { return testStruct1{} }))
+	a.NoError(r.Add(func(factory func() (testInterface2, error)) Main {
+		return testMainFunc(func() error {
+			for i := 0; i < 3; i++ {
+				_, err := factory()
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}))
+	errs := r.Run()
+	a.Equal(0, len(errs), errs)
+	a.Equal("made3 made2 made1", strings.Join(order, " "))
+}
+
+func TestAddFactoryInvalid(t *testing.T) {
+	a := assert.New(t)
+
+	r := New()
+	a.True(errors.Is(r.AddFactory(func() (testInterface1, testInterface2) { return nil, nil }),
+		ErrFactoryInvalid))
+	a.NoError(r.AddFactory(new2))
+	a.True(errors.Is(r.AddFactory(new2Closer), ErrFactoryConflict))
+}
+
+//********************
+type testStruct34 struct{ testStruct3 }
+
+func (r testStruct34) Method4() string { return "testStruct34.Method4" }
+
+func TestBind(t *testing.T) {
+	a := assert.New(t)
+
+	var got testInterface3
+	closes := 0
+	r := New()
+	a.NoError(r.AddInterfaces((*testInterface3)(nil), (*testInterface4)(nil)))
+	a.NoError(r.Bind((*testInterface3)(nil), (*testConcrete)(nil)))
+	a.NoError(r.Add(func() *testConcrete { return &testConcrete{closes: &closes} }))
+	a.NoError(r.Add(func() testStruct34 { return testStruct34{} }))
+	a.NoError(r.Add(func(i testInterface3, i4 testInterface4) Main {
+		got = i
+		return testMain{}
+	}))
+	errs := r.Run()
+	a.Equal(0, len(errs), errs)
+	a.Equal("testConcrete.Method3", got.Method3())
+	a.Equal(1, closes)
+}
+
+func TestBindErrors(t *testing.T) {
+	a := assert.New(t)
+
+	r := New()
+	a.True(errors.Is(r.Bind(testStruct3{}, (*testConcrete)(nil)), ErrNotInterfacePointer))
+	a.True(errors.Is(r.Bind((*testInterface4)(nil), (*testConcrete)(nil)), ErrBindNotImplemented))
+	a.True(errors.Is(r.Bind((*testInterface3)(nil), nil), ErrBindNotImplemented))
+	a.NoError(r.Bind((*testInterface3)(nil), (*testConcrete)(nil)))
+	a.True(errors.Is(r.Bind((*testInterface3)(nil), testStruct3{}), ErrBindConflict))
+}
+
+//********************
+func TestValueCounts(t *testing.T) {
+	a := assert.New(t)
+
+	r := New()
+	a.NoError(r.Add(new2Closer))
+	a.NoError(r.Add(discover[testInterface2](testStruct2{}, testStruct2{})))
+	a.NoError(r.Add(new1ConsumeSice2))
+	_, errs := r.BuildOnly()
+	a.Equal(0, len(errs), errs)
+	a.Equal(4, r.ValueCount())
+	a.Equal(1, r.CloserCount())
+}
+
+func TestMaxValues(t *testing.T) {
+	a := assert.New(t)
+
+	r := New()
+	r.SetMaxValues(2)
+	a.NoError(r.Add(new2Closer))
+	a.NoError(r.Add(discover[testInterface2](testStruct2{}, testStruct2{})))
+	a.NoError(r.Add(new1ConsumeSice2))
+	a.NoError(r.Add(newMain))
+	errs := r.Run()
+	a.Equal(2, len(errs), errs)
+	a.True(errors.Is(errs[0], ErrValueLimit), errs[0])
+	a.True(errors.Is(errs[1], errCloser), errs[1])
+}
+
+//********************
+func TestCloserList(t *testing.T) {
+	a := assert.New(t)
+
+	var during, after []interface{}
+	var order []string
+	r := New()
+	a.NoError(r.Add(new2Closer))
+	a.NoError(r.Add(func(i testInterface2, list CloserList) testInterface3 {
+		during = list.Closers()
+		return testStruct3{}
+	}))
+	a.NoError(r.Add(func() testInterface1 {
+		return testOrderCloser1{testOrderCloser: testOrderCloser{order: &order}}
+	}))
+	a.NoError(r.Add(func(i testInterface1, i3 testInterface3, list CloserList) Main {
+		return testMainFunc(func() error {
+			after = list.Closers()
+			return nil
+		})
+	}))
+	errs := r.Run()
+	a.Equal(1, len(errs), errs)
+	a.Equal(1, len(during))
+	a.Equal(2, len(after))
+	_, ok := after[0].(testOrderCloser1)
+	a.True(ok)
+	_, ok = after[1].(testStruct2Closer)
+	a.True(ok)
+}
+
+//********************
+func TestSetCloseSorter(t *testing.T) {
+	a := assert.New(t)
+
+	var order []string
+	newCloser := func(name string) func() testInterface2 {
+		return func() testInterface2 { return testOrderCloser{name: name, order: &order} }
+	}
+	r := New()
+	for _, name := range []string{"c0", "c1", "c2"} {
+		a.NoError(r.Add(newCloser(name)))
+	}
+	a.NoError(r.Add(new1ConsumeSice2))
+	a.NoError(r.Add(newMain))
+	var given []int
+	r.SetCloseSorter(func(closers []CloserInfo) []CloserInfo {
+		for _, v := range closers {
+			given = append(given, v.Index)
+		}
+		return []CloserInfo{closers[1], closers[2], closers[0]}
+	})
+	errs := r.Run()
+	a.Equal(0, len(errs), errs)
+	a.Equal("[2 1 0]", fmt.Sprint(given))
+	a.Equal("c1 c0 c2", strings.Join(order, " "))
+}
+
+//********************
+func TestReplace(t *testing.T) {
+	a := assert.New(t)
+
+	for _, test := range []struct {
+		value testInterface2
+		want  string
+	}{
+		{testStruct2{}, "testStruct2.Method"},
+		{testStruct2DelayCloser{}, "testStruct2DelayCloser.Method"},
+	} {
+		var got string
+		r := New()
+		reg, err := r.Register(new2)
+		a.NoError(err)
+		a.NoError(r.Add(func(i testInterface2) Main {
+			got = i.Method()
+			return testMain{}
+		}))
+		value := test.value
+		a.NoError(r.Replace(reg, func() testInterface2 { return value }))
+		r.Run()
+		a.Equal(test.want, got)
+		a.True(errors.Is(r.Replace(reg, new2), ErrReplaceAfterRun))
+	}
+}
+
+func TestReplaceErrors(t *testing.T) {
+	a := assert.New(t)
+
+	r := New()
+	reg, err := r.Register(new2)
+	a.NoError(err)
+	a.True(errors.Is(r.Replace(reg, new3Consume2), ErrReplaceMismatch))
+	a.True(errors.Is(r.Replace(Registration{}, new2), ErrRegistrationInvalid))
+	a.True(errors.Is(r.Replace(reg, nil), ErrProducerNil))
+
+	// a different type with the same name is still a mismatch
+	type testInterface2 interface{ Method() string }
+	a.True(errors.Is(
+		r.Replace(reg, func() testInterface2 { return testStruct2{} }),
+		ErrReplaceMismatch,
+	))
+}
+
+//********************
+func TestAddInPhase(t *testing.T) {
+	a := assert.New(t)
+
+	var order []string
+	newCloser := func(name string) func() testInterface2 {
+		return func() testInterface2 { return testOrderCloser{name: name, order: &order} }
+	}
+	r := New()
+	a.NoError(r.Add(newCloser("conn1")))
+	a.NoError(r.AddInPhase(2, newCloser("ingress")))
+	a.NoError(r.AddInPhase(1, newCloser("worker1")))
+	a.NoError(r.Add(newCloser("conn2")))
+	a.NoError(r.AddInPhase(1, newCloser("worker2")))
+	a.NoError(r.Add(new1ConsumeSice2))
+	a.NoError(r.Add(newMain))
+	errs := r.Run()
+	a.Equal(0, len(errs), errs)
+	a.Equal("ingress worker2 worker1 conn2 conn1", strings.Join(order, " "))
+}
+
+//********************
+func TestMissingDependencies(t *testing.T) {
+	a := assert.New(t)
+
+	r := New()
+	a.NoError(r.Add(newMainConsume13))
+	a.NoError(r.Add(new1Consume2))
+	a.NoError(r.Add(new1ConsumeSice2))
+	a.NoError(r.Add(func(ctx context.Context, c <-chan testEvent, i testInterface3) testInterface4 {
+		return nil
+	}))
+	a.NoError(r.Add(func() chan testEvent { return nil }))
+	a.NoError(r.AddLazy(func(i testInterface4) testInterface3 { return testStruct3{} }))
+	a.Equal(0, len(r.CallOrder()))
+	missing := r.MissingDependencies()
+	a.Equal("[runner.testInterface2]", fmt.Sprint(missing))
+}
+
+//********************
+func TestLoggerProvided(t *testing.T) {
+	a := assert.New(t)
+
+	logger := &testLogger{}
+	r := New()
+	r.SetLogger(logger)
+	a.NoError(r.Add(func(l general.Logger) Main {
+		_ = l.Log("from producer")
+		return testMain{}
+	}))
+	errs := r.Run()
+	a.Equal(0, len(errs), errs)
+	a.Equal(1, len(logger.lines))
+	a.Equal("from producer", logger.lines[0])
+}
+
+func TestLoggerNotSet(t *testing.T) {
+	a := assert.New(t)
+
+	r := New()
+	a.NoError(r.Add(func(l general.Logger) Main {
+		a.NoError(l.Logf("discarded %v", 1))
+		return testMain{}
+	}))
+	errs := r.Run()
+	a.Equal(0, len(errs), errs)
+}
+
+//********************
+func TestUncountedValue(t *testing.T) {
+	a := assert.New(t)
+
+	var order []string
+	r := New()
+	a.NoError(r.AddInterfaces((*testInterface3)(nil)))
+	a.NoError(r.Add(new2))
+	a.NoError(r.Add(func() testInterface1 {
+		// registered while building, after the producers of testInterface2 were counted
+		a.NoError(r.AddInterfaces((*testInterface2)(nil)))
+		return testStruct1{}
+	}))
+	a.NoError(r.Add(func(i testInterface1) testOrderCloser3 {
+		return testOrderCloser3{testOrderCloser: testOrderCloser{name: "uncounted", order: &order}}
+	}))
+	accessor, errs := r.BuildOnly()
+	a.Equal(0, len(errs), errs)
+
+	single, err := Get[testInterface2](accessor)
+	a.NoError(err)
+	a.Equal("testStruct2.Method", single.Method())
+	all, err := Get[[]testInterface2](accessor)
+	a.NoError(err)
+	a.Equal(2, len(all))
+	a.Equal(0, len(accessor.Close()))
+	a.Equal("uncounted", strings.Join(order, " "))
+}
+
+//********************
+func TestAddWhileBuilding(t *testing.T) {
+	a := assert.New(t)
+
+	var got testInterface3
+	r := New()
+	a.NoError(r.Add(func() testInterface2 {
+		a.NoError(r.Add(new3Consume2))
+		return testStruct2{}
+	}))
+	a.NoError(r.Add(func(i testInterface3) Main {
+		got = i
+		return testMain{}
+	}))
+	errs := r.Run()
+	a.Equal(0, len(errs), errs)
+	a.NotNil(got)
+	a.Equal("testStruct3.Method3", got.Method3())
+}
+
+//********************
+func TestAddWithTimeout(t *testing.T) {
+	a := assert.New(t)
+
+	release := make(chan struct{})
+	defer close(release)
+	r := New()
+	a.NoError(r.AddWithTimeout(10*time.Millisecond, func() testInterface2 {
+		<-release
+		return testStruct2{}
+	}))
+	a.NoError(r.AddWithTimeout(time.Second, new1Consume2))
+	a.NoError(r.Add(newMain))
+	errs := r.Run()
+	a.Equal(1, len(errs), errs)
+	a.True(errors.Is(errs[0], ErrProducerTimeout), errs[0])
+	a.ErrorContains(errs[0], "TestAddWithTimeout")
+}
+
+//********************
+func new2Consume3(i testInterface3) testInterface2 { return testStruct2{} }
+
+func TestMissingDependencyChain(t *testing.T) {
+	a := assert.New(t)
+
+	errs := Run([]interface{}{newMain, new1Consume2, new2Consume3})
+	a.Equal(1, len(errs), errs)
+	a.True(errors.Is(errs[0], ErrNoProducerMakes), errs[0])
+	a.ErrorContains(
+		errs[0],
+		"chain: runner.Main needs runner.testInterface1 needs runner.testInterface2 needs "+
+			"runner.testInterface3",
+	)
+}
+
+func TestMissingDependencyChainCircular(t *testing.T) {
+	a := assert.New(t)
+
+	errs := Run([]interface{}{newMain, new1Consume2, new2Consume1})
+	a.Equal(3, len(errs), errs)
+	a.True(errors.Is(errs[1], ErrMissingDependency), errs[1])
+	a.ErrorContains(
+		errs[1],
+		"chain: runner.Main needs runner.testInterface1 needs runner.testInterface2",
+	)
+}
+
+//********************
+func TestGroup(t *testing.T) {
+	a := assert.New(t)
+
+	var plugins, empty []testInterface2
+	var err, emptyErr error
+	r := New()
+	a.NoError(ProvideGroup[testInterface2](r, "plugins", new2))
+	a.NoError(ProvideGroup[testInterface2](
+		r,
+		"plugins",
+		discover[testInterface2](testStruct2DelayCloser{}, testStruct2{}),
+	))
+	a.True(errors.Is(ProvideGroup[testInterface3](r, "plugins", new2), ErrGroupType))
+	a.NoError(r.Add(func(i []testInterface2, ctx context.Context) Main {
+		a.Equal(0, len(i))
+		getter := FromContext(ctx)
+		return testMainFunc(func() error {
+			plugins, err = GetGroup[testInterface2](getter, "plugins")
+			empty, emptyErr = GetGroup[testInterface2](getter, "none")
+			return nil
+		})
+	}))
+	errs := r.Run()
+	a.Equal(1, len(errs), errs)
+	a.True(errors.Is(errs[0], errDelayCloser), errs[0])
+	a.NoError(err)
+	a.Equal(3, len(plugins))
+	a.Equal("testStruct2DelayCloser.Method", plugins[1].Method())
+	a.NoError(emptyErr)
+	a.NotNil(empty)
+	a.Equal(0, len(empty))
+}
+
+func TestGetGroupWrongType(t *testing.T) {
+	a := assert.New(t)
+
+	r := New()
+	a.NoError(r.AddToGroup("things", new2))
+	accessor, errs := r.BuildOnly()
+	a.Equal(0, len(errs), errs)
+	_, err := GetGroup[testInterface3](accessor, "things")
+	a.True(errors.Is(err, ErrGroupType), err)
+}
+
+//********************
+func TestFuncMain(t *testing.T) {
+	a := assert.New(t)
+
+	called := false
+	errs := Run([]interface{}{
+		new2,
+		func(i testInterface2) func() error {
+			return func() error {
+				called = true
+				return nil
+			}
+		},
+	})
+	a.Equal(0, len(errs), errs)
+	a.True(called)
+}
+
+func TestFuncMainAndMain(t *testing.T) {
+	a := assert.New(t)
+
+	errs := Run([]interface{}{
+		func() func() error { return func() error { return nil } },
+		func() Main { return testMain{} },
+	})
+	a.Equal(1, len(errs), errs)
+	a.True(errors.Is(errs[0], ErrMultipleMain), errs[0])
+	a.ErrorContains(errs[0], "runner.mainFunc")
+	a.ErrorContains(errs[0], "runner.testMain")
+}
+
+//********************
+type testOrderCloser3 struct {
+	testStruct3
+	testOrderCloser
+}
+
+func TestCloseBarrier(t *testing.T) {
+	a := assert.New(t)
+
+	var order []string
+	release := make(chan struct{})
+	r := New()
+	a.NoError(r.Add(func() testInterface3 {
+		return testOrderCloser3{testOrderCloser: testOrderCloser{name: "server", order: &order}}
+	}))
+	a.NoError(r.Add(func(i testInterface3, registry CloserRegistry) testInterface2 {
+		registry.AddBarrier(release, time.Second)
+		registry.AddBarrier(nil, 5*time.Millisecond)
+		return testOrderCloser{name: "deregister", order: &order}
+	}))
+	a.NoError(r.Add(new1Consume2))
+	a.NoError(r.Add(newMain))
+	start := time.Now()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+	}()
+	errs := r.Run()
+	a.Equal(0, len(errs), errs)
+	a.True(time.Since(start) >= 10*time.Millisecond)
+	a.Equal("deregister server", strings.Join(order, " "))
+}
+
+//********************
+func TestTypeSnapshotDiff(t *testing.T) {
+	a := assert.New(t)
+
+	build := func(producers ...interface{}) TypeSnapshot {
+		r := New()
+		a.Equal(0, len(r.AddAll(producers...)))
+		_, errs := r.BuildOnly()
+		a.Equal(0, len(errs), errs)
+		return r.TypeSnapshot()
+	}
+	before := build(new2, new1Consume2)
+	after := build(new2, new3Consume2)
+	diff := before.Diff(after)
+	a.Equal("[runner.testInterface3]", fmt.Sprint(diff.Added))
+	a.Equal("[runner.testInterface1]", fmt.Sprint(diff.Removed))
+	a.Equal("[runner.testInterface2]", fmt.Sprint(diff.Retained))
+}
+
+//********************
+func TestSliceDependencyCases(t *testing.T) {
+	a := assert.New(t)
+
+	var received []testInterface2
+	newConsumer := func(i []testInterface2) testInterface1 {
+		received = i
+		return testStruct1{}
+	}
+
+	// none produced, an empty slice not nil
+	errs := Run([]interface{}{newConsumer, newMain})
+	a.Equal(0, len(errs), errs)
+	a.True(received != nil)
+	a.Equal(0, len(received))
+
+	// all best effort producers failed, also empty
+	received = nil
+	r := New()
+	a.NoError(r.AddBestEffort(new2Fails))
+	a.NoError(r.AddBestEffort(new2Fails))
+	a.NoError(r.Add(newConsumer))
+	a.NoError(r.Add(newMain))
+	errs = r.Run()
+	a.Equal(0, len(errs), errs)
+	a.True(received != nil)
+	a.Equal(0, len(received))
+
+	// some pending, the consumer added first still gets every element in provide order
+	received = nil
+	new2Consume3 := func(i testInterface3) testInterface2 { return testStruct2DelayCloser{} }
+	new3 := func() testInterface3 { return testStruct3{} }
+	errs = Run([]interface{}{newConsumer, new2Consume3, new2, newMain, new3})
+	a.Equal(1, len(errs), errs)
+	a.Equal(2, len(received))
+	a.Equal("testStruct2.Method", received[0].Method())
+	a.Equal("testStruct2DelayCloser.Method", received[1].Method())
+}
+
+//********************
+func TestRunWith(t *testing.T) {
+	a := assert.New(t)
+
+	logger := &testLogger{}
+	errs := RunWith(
+		[]Option{
+			WithCloseTimeout(5 * time.Millisecond),
+			WithLogger(logger),
+			func(r Runner) { a.NoError(r.AddBestEffort(new2Fails)) },
+		},
+		[]interface{}{new2StuckDelayCloser, new1ConsumeSice2, newMain},
+	)
+	a.Equal(1, len(errs), errs)
+	a.True(errors.Is(errs[0], ErrDelayCloserTimeout), errs[0])
+	// the best effort failure and the closer the budget ran out on
+	a.Equal(2, len(logger.lines))
+	a.True(strings.Contains(logger.lines[1], "testStruct2StuckDelayCloser"), logger.lines)
+}
+
+//********************
+type testApp interface {
+	Main
+	Name() string
+}
+
+type testAppImpl struct{ testMain }
+
+func (r testAppImpl) Name() string { return "app" }
+
+func TestEmbeddedMainPreferred(t *testing.T) {
+	a := assert.New(t)
+
+	// new3ServiceMain also implements Main but returns errMainError if run
+	errs := Run([]interface{}{func() testApp { return testAppImpl{} }, new3ServiceMain})
+	a.Equal(0, len(errs), errs)
+}
+
+//********************
+func TestErrAbort(t *testing.T) {
+	a := assert.New(t)
+
+	var order []string
+	mainCalled := false
+	errs := Run([]interface{}{
+		func() testInterface2 { return testOrderCloser{name: "built", order: &order} },
+		func(i testInterface2) (testInterface1, error) {
+			return nil, fmt.Errorf("version printed: %w", ErrAbort)
+		},
+		func(i testInterface1) Main {
+			mainCalled = true
+			return testMain{}
+		},
+	})
+	a.Equal(0, len(errs), errs)
+	a.False(mainCalled)
+	a.Equal("built", strings.Join(order, " "))
+	a.Equal(ExitCodeOK, ExitCode([]error{ErrAbort}))
+}
+
+//********************
+func TestBuildStats(t *testing.T) {
+	a := assert.New(t)
+
+	var stats BuildStats
+	r := New()
+	a.NoError(r.Add(new2Closer))
+	a.NoError(r.Add(new1ConsumeSice2))
+	a.NoError(r.Add(func(s BuildStats) Main {
+		stats = s
+		return testMain{}
+	}))
+	errs := r.Run()
+	a.Equal(1, len(errs), errs)
+	a.Equal(3, stats.Producers())
+	a.Equal(3, stats.Values())
+	a.Equal(1, stats.Closers())
+	a.True(stats.Duration() > 0)
+}
+
+//********************
+func TestExcludeFromClose(t *testing.T) {
+	a := assert.New(t)
+
+	closes := 0
+	r := New()
+	a.True(errors.Is(r.ExcludeFromClose(nil), ErrExcludeInvalid))
+	a.NoError(r.ExcludeFromClose((*testConcrete)(nil)))
+	a.NoError(r.AddInterfaces((*testInterface1)(nil), (*testInterface3)(nil)))
+	a.NoError(r.Add(func() *testConcrete { return &testConcrete{closes: &closes} }))
+	a.NoError(r.Add(func() io.Closer { return countingCloser{closes: &closes} }))
+	a.NoError(r.Add(func(i1 testInterface1, i3 testInterface3, c io.Closer) Main {
+		return testMain{}
+	}))
+	errs := r.Run()
+	a.Equal(0, len(errs), errs)
+	a.Equal(1, closes)
+
+	closes = 0
+	r = New()
+	a.NoError(r.ExcludeFromClose((*io.Closer)(nil)))
+	a.NoError(r.Add(func() io.Closer { return countingCloser{closes: &closes} }))
+	a.NoError(r.Add(func() Main { return testMain{} }))
+	errs = r.Run()
+	a.Equal(0, len(errs), errs)
+	a.Equal(0, closes)
+}
+
+//********************
+func TestShutdownNotifier(t *testing.T) {
+	a := assert.New(t)
+
+	var order []string
+	errMain := errors.New("main error")
+	r := New()
+	a.NoError(r.Add(func(notifier ShutdownNotifier) testInterface2 {
+		notifier.OnShutdown(func(err error) {
+			a.True(errors.Is(err, errMain))
+			order = append(order, "first")
+		})
+		notifier.OnShutdown(func(err error) { order = append(order, "second") })
+		return testOrderCloser{name: "close", order: &order}
+	}))
+	a.NoError(r.Add(func(i testInterface2) Main {
+		return testMainFunc(func() error { return errMain })
+	}))
+	errs := r.Run()
+	a.Equal(1, len(errs), errs)
+	a.Equal("first second close", strings.Join(order, " "))
+}
+
+//********************
+func TestInventory(t *testing.T) {
+	a := assert.New(t)
+
+	r := New()
+	a.NoError(r.Add(new2))
+	a.NoError(r.Add(new1Consume2))
+	_, errs := r.BuildOnly()
+	a.Equal(0, len(errs), errs)
+	inventory := r.Inventory()
+	a.Equal(2, len(inventory))
+	a.Equal(InventoryEntry{
+		Interface: "runner.testInterface2",
+		Concrete:  "runner.testStruct2",
+		Producer:  "github.com/blbgo/runner.new2",
+	}, inventory[0])
+	a.Equal("runner.testStruct1", inventory[1].Concrete)
+	a.Equal("github.com/blbgo/runner.new1Consume2", inventory[1].Producer)
+
+	// grouped and keyed values are in the inventory too
+	r = New()
+	a.NoError(r.AddToGroup("group", new2))
+	a.NoError(r.AddKeyed("key", func() testInterface3 { return testStruct3{} }))
+	a.NoError(r.Add(func() testInterface1 { return testStruct1{} }))
+	_, errs = r.BuildOnly()
+	a.Equal(0, len(errs), errs)
+	inventory = r.Inventory()
+	a.Equal(3, len(inventory))
+	a.Equal("github.com/blbgo/runner.new2", inventory[0].Producer)
+	a.Equal("runner.testInterface3", inventory[1].Interface)
+}
+
+//********************
+func TestAddAsync(t *testing.T) {
+	a := assert.New(t)
+
+	slow := func(value interface{}) <-chan testInterface2 {
+		ch := make(chan testInterface2, 1)
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			ch <- value.(testInterface2)
+		}()
+		return ch
+	}
+	r := New()
+	a.NoError(r.AddAsync(time.Second, func() <-chan testInterface2 { return slow(testStruct2{}) }))
+	a.NoError(r.AddAsync(time.Second, func() (<-chan testInterface2, error) {
+		return slow(testStruct2{}), nil
+	}))
+	a.NoError(r.Add(new1ConsumeSice2))
+	start := time.Now()
+	_, errs := r.BuildOnly()
+	a.Equal(0, len(errs), errs)
+	a.True(time.Since(start) < 100*time.Millisecond, "async producers did not run in parallel")
+
+	r = New()
+	a.NoError(r.AddAsync(10*time.Millisecond, func() <-chan testInterface2 {
+		return make(chan testInterface2)
+	}))
+	a.NoError(r.Add(new1Consume2))
+	_, errs = r.BuildOnly()
+	a.Equal(1, len(errs), errs)
+	a.True(errors.Is(errs[0], ErrProducerTimeout), errs)
+
+	a.True(errors.Is(r.AddAsync(time.Second, new2), ErrAsyncInvalid))
+}
+
+//********************
+func TestParallelBuild(t *testing.T) {
+	a := assert.New(t)
+
+	var order []string
+	slow := func(name string) func() testInterface2 {
+		return func() testInterface2 {
+			time.Sleep(50 * time.Millisecond)
+			return testOrderCloser{name: name, order: &order}
+		}
+	}
+	r := New()
+	r.SetParallelBuild(4)
+	a.NoError(r.Add(slow("first")))
+	a.NoError(r.Add(slow("second")))
+	a.NoError(r.Add(slow("third")))
+	a.NoError(r.Add(func(i []testInterface2) Main { return testMain{} }))
+	start := time.Now()
+	errs := r.Run()
+	a.Equal(0, len(errs), errs)
+	a.True(time.Since(start) < 100*time.Millisecond, "producers were not called in parallel")
+	a.Equal("third second first", strings.Join(order, " "))
+
+	r = New()
+	r.SetParallelBuild(2)
+	order = nil
+	a.NoError(r.Add(slow("closed")))
+	a.NoError(r.Add(func() (testInterface1, error) { return nil, errBestEffort }))
+	a.NoError(r.Add(slow("also closed")))
+	a.NoError(r.Add(func(i []testInterface2, i1 testInterface1) Main { return testMain{} }))
+	errs = r.Run()
+	a.Equal(1, len(errs), errs)
+	a.Equal("also closed closed", strings.Join(order, " "))
+}
+
+//********************
+func TestProducerInterceptor(t *testing.T) {
+	a := assert.New(t)
+
+	var names []string
+	r := New()
+	r.SetProducerInterceptor(func(name string, call func() error) error {
+		names = append(names, name[strings.LastIndex(name, ".")+1:])
+		return call()
+	})
+	a.NoError(r.Add(new2))
+	a.NoError(r.Add(newMain))
+	a.NoError(r.Add(new1Consume2))
+	errs := r.Run()
+	a.Equal(0, len(errs), errs)
+	a.Equal("new2 new1Consume2 newMain", strings.Join(names, " "))
+
+	attempts := 0
+	r = New()
+	r.SetProducerInterceptor(func(name string, call func() error) error {
+		err := call()
+		if err != nil {
+			err = call()
+		}
+		return err
+	})
+	a.NoError(r.Add(func() (testInterface2, error) {
+		attempts++
+		if attempts == 1 {
+			return nil, errBestEffort
+		}
+		return testStruct2{}, nil
+	}))
+	a.NoError(r.Add(new1Consume2))
+	_, errs = r.BuildOnly()
+	a.Equal(0, len(errs), errs)
+	a.Equal(2, attempts)
+
+	r = New()
+	r.SetProducerInterceptor(func(name string, call func() error) error { return nil })
+	a.NoError(r.Add(new2))
+	_, errs = r.BuildOnly()
+	a.Equal(1, len(errs), errs)
+	a.True(errors.Is(errs[0], ErrInterceptorNoCall), errs)
+}
+
+//********************
+func TestAddWithRetry(t *testing.T) {
+	a := assert.New(t)
+
+	attempts := 0
+	flaky := func(failures int) func() (testInterface2, error) {
+		return func() (testInterface2, error) {
+			attempts++
+			if attempts <= failures {
+				return nil, errBestEffort
+			}
+			return testStruct2{}, nil
+		}
+	}
+	r := New()
+	a.NoError(r.AddWithRetry(3, time.Millisecond, flaky(2)))
+	a.NoError(r.Add(new1Consume2))
+	_, errs := r.BuildOnly()
+	a.Equal(0, len(errs), errs)
+	a.Equal(3, attempts)
+
+	attempts = 0
+	r = New()
+	a.NoError(r.AddWithRetry(2, time.Millisecond, flaky(2)))
+	a.NoError(r.Add(new1Consume2))
+	_, errs = r.BuildOnly()
+	a.Equal(1, len(errs), errs)
+	a.True(errors.Is(errs[0], errBestEffort), errs)
+	a.Equal(2, attempts)
+}
+
+//********************
+type testCommand int
+
+func TestAddKeyed(t *testing.T) {
+	a := assert.New(t)
+
+	var got map[testCommand]testInterface2
+	consume := func(m map[testCommand]testInterface2) testInterface1 {
+		got = m
+		return testStruct1{}
+	}
+	r := New()
+	a.NoError(r.Add(consume))
+	a.NoError(r.AddKeyed(testCommand(1), new2))
+	a.NoError(r.AddKeyed(testCommand(2), new2Closer))
+	_, errs := r.BuildOnly()
+	a.Equal(0, len(errs), errs)
+	a.Equal(2, len(got))
+	a.Equal(testStruct2Closer{}, got[2])
+	a.Equal(1, r.CloserCount())
+
+	r = New()
+	a.NoError(r.Add(consume))
+	_, errs = r.BuildOnly()
+	a.Equal(0, len(errs), errs)
+	a.NotNil(got)
+	a.Equal(0, len(got))
+
+	r = New()
+	a.NoError(r.Add(consume))
+	a.NoError(r.AddKeyed(testCommand(1), new2))
+	a.NoError(r.AddKeyed(testCommand(1), new2))
+	_, errs = r.BuildOnly()
+	a.Equal(1, len(errs), errs)
+	a.True(errors.Is(errs[0], ErrKeyedDuplicate), errs)
+
+	a.True(errors.Is(r.AddKeyed([]int{}, new2), ErrKeyedInvalid))
+	a.True(errors.Is(r.AddKeyed(nil, new2), ErrKeyedInvalid))
+	a.True(errors.Is(
+		r.AddKeyed(testCommand(3), func() (testInterface1, testInterface2) { return nil, nil }),
+		ErrKeyedInvalid,
+	))
+}
+
+//********************
+func TestTimings(t *testing.T) {
+	a := assert.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := New()
+	a.NoError(r.Add(func() Main {
+		return testMainFunc(func() error {
+			<-ctx.Done()
+			time.Sleep(20 * time.Millisecond)
+			return nil
+		})
+	}))
+	go cancel()
+	errs := r.RunContext(ctx)
+	a.Equal(0, len(errs), errs)
+	timings := r.Timings()
+	a.False(timings.ShutdownRequested.IsZero())
+	a.True(timings.MainReturned.Sub(timings.ShutdownRequested) >= 20*time.Millisecond)
+	a.False(timings.CloseStarted.Before(timings.MainReturned))
+	a.False(timings.CloseCompleted.Before(timings.CloseStarted))
+
+	r = New()
+	a.NoError(r.Add(func() Main { return testMain{} }))
+	errs = r.Run()
+	a.Equal(0, len(errs), errs)
+	a.True(r.Timings().ShutdownRequested.IsZero())
+	a.False(r.Timings().MainReturned.IsZero())
+}
+
+//********************
+func TestAddPrimary(t *testing.T) {
+	a := assert.New(t)
+
+	var primary testInterface2
+	var all []testInterface2
+	r := New()
+	a.NoError(r.Add(new2))
+	a.NoError(r.AddPrimary(new2Closer))
+	a.NoError(r.Add(new2))
+	a.NoError(r.Add(func(i testInterface2, i2 []testInterface2) testInterface1 {
+		primary = i
+		all = i2
+		return testStruct1{}
+	}))
+	r.SetStrictSingletons(true)
+	_, errs := r.BuildOnly()
+	a.Equal(0, len(errs), errs)
+	a.Equal(testStruct2Closer{}, primary)
+	a.Equal(3, len(all))
+
+	a.True(errors.Is(r.AddPrimary(new2), ErrPrimaryConflict))
+}
+
+//********************
+type testWiring struct {
+	New2      func() testInterface2
+	New1      func(testInterface2) testInterface1
+	Name      string
+	newMain   func(testInterface1) Main
+	NotSet    func(testInterface1) testInterface3
+	Unchecked int
+}
+
+func TestAddFromStruct(t *testing.T) {
+	a := assert.New(t)
+
+	r := New()
+	errs := r.AddFromStruct(&testWiring{New2: new2, New1: new1Consume2, newMain: newMain}, false)
+	a.Equal(1, len(errs), errs)
+	a.True(errors.Is(errs[0], ErrProducerNil), errs)
+	a.ErrorContains(errs[0], "NotSet")
+	a.NoError(r.Add(newMain))
+	errs = r.Run()
+	a.Equal(0, len(errs), errs)
+
+	r = New()
+	errs = r.AddFromStruct(&testWiring{New2: new2, New1: new1Consume2, NotSet: new3Consume1}, true)
+	a.Equal(2, len(errs), errs)
+	a.True(errors.Is(errs[0], ErrStructInvalid), errs)
+	a.ErrorContains(errs[1], "Unchecked")
+
+	errs = r.AddFromStruct(testWiring{}, false)
+	a.Equal(1, len(errs), errs)
+	a.True(errors.Is(errs[0], ErrStructInvalid), errs)
+}
+
+//********************
+type testDescribable interface{ Describe() string }
+
+func (r testStruct1) Describe() string { return "testStruct1" }
+func (r testStruct2) Describe() string { return "testStruct2" }
+
+func TestCollectByInterface(t *testing.T) {
+	a := assert.New(t)
+
+	var described []string
+	r := New()
+	a.NoError(r.CollectByInterface((*testDescribable)(nil)))
+	a.NoError(r.Add(func(d []testDescribable) Main {
+		for _, v := range d {
+			described = append(described, v.Describe())
+		}
+		return testMain{}
+	}))
+	a.NoError(r.Add(new1Consume2))
+	a.NoError(r.Add(new2))
+	errs := r.Run()
+	a.Equal(0, len(errs), errs)
+	a.Equal("testStruct2 testStruct1", strings.Join(described, " "))
+
+	a.True(errors.Is(r.CollectByInterface(testStruct1{}), ErrNotInterfacePointer))
+}
+
+//********************
+func TestCloserRegistryWhileMainRuns(t *testing.T) {
+	a := assert.New(t)
+
+	var order []string
+	var registry CloserRegistry
+	r := New()
+	a.NoError(r.Add(func(c CloserRegistry) testInterface2 {
+		registry = c
+		return testOrderCloser{name: "built", order: &order}
+	}))
+	a.NoError(r.Add(func(i testInterface2) Main {
+		return testMainFunc(func() error {
+			var wg sync.WaitGroup
+			for _, name := range []string{"late1", "late2"} {
+				wg.Add(1)
+				go func(name string) {
+					defer wg.Done()
+					registry.Add(testOrderCloser{name: name, order: &order})
+				}(name)
+			}
+			wg.Wait()
+			return nil
+		})
+	}))
+	errs := r.Run()
+	a.Equal(0, len(errs), errs)
+	a.Equal(3, len(order))
+	a.Equal("built", order[2])
+}
+
+//********************
+func TestPlan(t *testing.T) {
+	a := assert.New(t)
+
+	r := New()
+	a.NoError(r.Add(newMain))
+	a.NoError(r.Add(new1Consume2))
+	a.NoError(r.AddInPhase(1, new2))
+	plan, err := r.Plan()
+	a.NoError(err)
+	a.Equal(`construction order:
+  1. github.com/blbgo/runner.new2 provides [runner.testInterface2]
+  2. github.com/blbgo/runner.new1Consume2 provides [runner.testInterface1]
+  3. github.com/blbgo/runner.newMain provides [runner.Main]
+close order (of every producer, closers are closed in this order):
+  1. github.com/blbgo/runner.new2 phase 1
+  2. github.com/blbgo/runner.newMain phase 0
+  3. github.com/blbgo/runner.new1Consume2 phase 0
+`, plan)
+	a.Equal(0, len(r.CallOrder()))
+
+	r = New()
+	a.NoError(r.Add(newMain))
+	plan, err = r.Plan()
+	a.True(errors.Is(err, ErrMissingDependency), err)
+	a.ErrorContains(err, "runner.testInterface1")
+	a.Equal("construction order:\nclose order (of every producer, closers are closed in this order):\n", plan)
+}
+
+//********************
+type testContextMain struct{}
+
+func (r testContextMain) RunContext(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func TestContextMain(t *testing.T) {
+	a := assert.New(t)
+
+	closes := 0
+	ctx, cancel := context.WithCancel(context.Background())
+	r := New()
+	a.NoError(r.Add(func() io.Closer { return countingCloser{closes: &closes} }))
+	a.NoError(r.Add(func(c io.Closer) ContextMain { return testContextMain{} }))
+	r.OnStarted(cancel)
+	errs := r.RunContext(ctx)
+	a.Equal(0, len(errs), errs)
+	a.Equal(1, closes)
+}
+
+//********************
+type testAlias2 = testInterface2
+
+type testDefined2 testInterface2
+
+func TestInterfaceAlias(t *testing.T) {
+	a := assert.New(t)
+
+	r := New()
+	a.NoError(r.Add(func() testAlias2 { return testStruct2{} }))
+	a.NoError(r.Add(func(i testAlias2, i2 testInterface2) Main { return testMain{} }))
+	errs := r.Run()
+	a.Equal(0, len(errs), errs)
+
+	r = New()
+	a.NoError(r.Add(new2))
+	a.NoError(r.Add(func(i testAlias2) Main { return testMain{} }))
+	errs = r.Run()
+	a.Equal(0, len(errs), errs)
+
+	r = New()
+	a.NoError(r.Add(new2))
+	a.NoError(r.Add(func(i testDefined2) Main { return testMain{} }))
+	errs = r.Run()
+	a.Equal(1, len(errs), errs)
+	a.True(errors.Is(errs[0], ErrNoProducerMakes), errs)
+}
+
+//********************
+func TestErrorTransform(t *testing.T) {
+	a := assert.New(t)
+
+	var closed []error
+	r := New()
+	r.SetErrorTransform(func(err error) error {
+		if errors.Is(err, errMainError) {
+			return nil
+		}
+		return fmt.Errorf("app: %w", err)
+	})
+	r.OnClosed(func(errs []error) { closed = errs })
+	a.NoError(r.Add(new2Closer))
+	a.NoError(r.Add(func(i testInterface2) Main { return testMainError{} }))
+	errs := r.Run()
+	a.Equal(1, len(errs), errs)
+	a.True(errors.Is(errs[0], errCloser), errs)
+	a.Equal("app: "+errCloser.Error(), errs[0].Error())
+	a.Equal(1, len(closed))
+}
+
+//********************
+type testStruct2Bound struct{}
+
+func (r testStruct2Bound) Method() string { return "testStruct2Bound.Method" }
+
+func TestBindFor(t *testing.T) {
+	a := assert.New(t)
+
+	var forMain, forOther testInterface2
+	r := New()
+	a.NoError(r.BindFor((*Main)(nil), (*testInterface2)(nil), func() testInterface2 {
+		return testStruct2Bound{}
+	}))
+	a.NoError(r.Add(new2))
+	a.NoError(r.Add(func(i testInterface2) testInterface1 {
+		forOther = i
+		return testStruct1{}
+	}))
+	a.NoError(r.Add(func(i testInterface2, i1 testInterface1) Main {
+		forMain = i
+		return testMain{}
+	}))
+	errs := r.Run()
+	a.Equal(0, len(errs), errs)
+	a.Equal(testStruct2Bound{}, forMain)
+	a.Equal(testStruct2{}, forOther)
+
+	a.True(errors.Is(
+		r.BindFor((*Main)(nil), (*testInterface2)(nil), new2),
+		ErrBindConflict,
+	))
+	a.True(errors.Is(
+		r.BindFor((*Main)(nil), (*testInterface1)(nil), new2),
+		ErrBindForInvalid,
+	))
+}
+
+//********************
+func TestBindForOnlyProvider(t *testing.T) {
+	a := assert.New(t)
+
+	r := New()
+	a.NoError(r.BindFor((*Main)(nil), (*testInterface3)(nil), func(i testInterface2) testInterface3 {
+		return testStruct3{}
+	}))
+	a.NoError(r.Add(func(i testInterface3) Main { return testMain{} }))
+	a.Equal("[runner.testInterface2]", fmt.Sprint(r.MissingDependencies()))
+
+	a.NoError(r.Add(new2))
+	a.Equal(0, len(r.MissingDependencies()))
+	plan, err := r.Plan()
+	a.NoError(err)
+	a.True(strings.Contains(plan, "provides [runner.Main]"), plan)
+	errs := r.Run()
+	a.Equal(0, len(errs), errs)
+}
+
+//********************
+type testBlockingCloser struct {
+	testStruct2
+	release chan struct{}
+}
+
+func (r testBlockingCloser) Close() error {
+	<-r.release
+	return nil
+}
+
+func TestCloseTimeoutBoundsCloser(t *testing.T) {
+	a := assert.New(t)
+
+	release := make(chan struct{})
+	defer close(release)
+	closes := 0
+	r := New()
+	r.SetCloseTimeout(20 * time.Millisecond)
+	a.NoError(r.Add(func() io.Closer { return countingCloser{closes: &closes} }))
+	a.NoError(r.Add(func(c io.Closer) testInterface2 {
+		return testBlockingCloser{release: release}
+	}))
+	a.NoError(r.Add(func(i testInterface2) Main { return testMain{} }))
+	errs := r.Run()
+	a.Equal(1, len(errs), errs)
+	a.True(errors.Is(errs[0], ErrCloseTimeout), errs)
+	a.ErrorContains(errs[0], "testBlockingCloser")
+	a.Equal(0, closes)
+}
+
+//********************
+func TestCloserTimeout(t *testing.T) {
+	a := assert.New(t)
+
+	release := make(chan struct{})
+	defer close(release)
+	closes := 0
+	r := New()
+	r.SetCloserTimeout(10 * time.Millisecond)
+	a.NoError(r.Add(func() io.Closer { return countingCloser{closes: &closes} }))
+	a.NoError(r.Add(func(c io.Closer) testInterface2 {
+		return testBlockingCloser{release: release}
+	}))
+	a.NoError(r.Add(func(i testInterface2) Main { return testMain{} }))
+	errs := r.Run()
+	a.Equal(1, len(errs), errs)
+	a.True(errors.Is(errs[0], ErrCloserTimeout), errs)
+	a.Equal(1, closes)
+}
+
+//********************
+func TestProvideHolder(t *testing.T) {
+	a := assert.New(t)
+
+	closes := 0
+	var held Holder[io.Closer]
+	r := New()
+	a.NoError(ProvideHolder[io.Closer](r, func() io.Closer { return countingCloser{closes: &closes} }))
+	a.NoError(r.Add(func(h Holder[io.Closer]) Main {
+		held = h
+		return testMainFunc(func() error {
+			var wg sync.WaitGroup
+			for i := 0; i < 4; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					h.Set(countingCloser{closes: &closes})
+					_ = h.Get()
+				}()
+			}
+			wg.Wait()
+			return nil
+		})
+	}))
+	errs := r.Run()
+	a.Equal(0, len(errs), errs)
+	a.NotNil(held.Get())
+	a.Equal(1, closes)
+
+	a.True(errors.Is(ProvideHolder[io.Closer](r, new2), ErrHolderInvalid))
+
+	r = New()
+	a.NoError(ProvideHolder[testInterface2](r, newNil2))
+	a.NoError(r.Add(func(h Holder[testInterface2]) Main { return testMain{} }))
+	errs = r.Run()
+	a.Equal(1, len(errs), errs)
+	a.True(errors.Is(errs[0], ErrProducerReturnedNil), errs)
+	a.ErrorContains(errs[0], "newNil2")
+}
+
+func newNil2() testInterface2 { return nil }
+
+//********************
+func TestShutdownHook(t *testing.T) {
+	a := assert.New(t)
+
+	var order []string
+	errHook := errors.New("hook error")
+	r := New()
+	r.AddShutdownHook(func() error {
+		order = append(order, "first")
+		return nil
+	})
+	r.AddShutdownHook(func() error {
+		order = append(order, "second")
+		return errHook
+	})
+	r.OnClosed(func(errs []error) { order = append(order, "closed") })
+	a.NoError(r.Add(newMain))
+	errs := r.Run()
+	a.Equal(2, len(errs), errs)
+	a.True(errors.Is(errs[1], errHook), errs)
+	a.Equal("second first closed", strings.Join(order, " "))
+}
+
+//********************
+type testObserver struct {
+	names []string
+	calls map[string]time.Duration
+}
+
+func (r *testObserver) ProducerCalled(name string, resolve time.Duration, call time.Duration) {
+	name = name[strings.LastIndex(name, ".")+1:]
+	r.names = append(r.names, name)
+	r.calls[name] = call
+}
+
+func TestObserver(t *testing.T) {
+	a := assert.New(t)
+
+	for _, workers := range []int{0, 2} {
+		observer := &testObserver{calls: map[string]time.Duration{}}
+		r := New()
+		r.SetObserver(observer)
+		r.SetParallelBuild(workers)
+		a.NoError(r.Add(newMain))
+		a.NoError(r.Add(new1Consume2))
+		a.NoError(r.Add(func() testInterface2 {
+			time.Sleep(20 * time.Millisecond)
+			return testStruct2{}
+		}))
+		errs := r.Run()
+		a.Equal(0, len(errs), errs)
+		a.Equal("func1 new1Consume2 newMain", strings.Join(observer.names, " "))
+		a.True(observer.calls["func1"] >= 20*time.Millisecond, observer.calls)
+		a.True(observer.calls["new1Consume2"] < 20*time.Millisecond, observer.calls)
+	}
+}
+
+//********************
+type testDocumented struct {
+	testStruct2
+	doc string
+}
+
+func (r testDocumented) Doc() string { return r.doc }
+
+func TestDocumentation(t *testing.T) {
+	a := assert.New(t)
+
+	var docs map[string]string
+	r := New()
+	a.NoError(r.Add(func() testInterface2 { return testDocumented{doc: "first"} }))
+	a.NoError(r.Add(func() testInterface2 { return testDocumented{doc: "second"} }))
+	a.NoError(r.Add(func(i []testInterface2) testInterface1 { return testStruct1{} }))
+	a.NoError(r.Add(func(documentation Documentation, i testInterface1) Main {
+		return testMainFunc(func() error {
+			docs = documentation.Docs()
+			return nil
+		})
+	}))
+	errs := r.Run()
+	a.Equal(0, len(errs), errs)
+	a.Equal(1, len(docs))
+	a.Equal("first\nsecond", docs["runner.testInterface2"])
+}
+
+//********************
+func TestChild(t *testing.T) {
+	a := assert.New(t)
+
+	var order []string
+	parent := New()
+	a.NoError(parent.Add(func() testInterface2 {
+		return testOrderCloser{name: "parent", order: &order}
+	}))
+	var children ChildRunners
+	a.NoError(parent.Add(func(c ChildRunners) testInterface3 {
+		children = c
+		return testStruct3{}
+	}))
+	parentAccessor, errs := parent.BuildOnly()
+	a.Equal(0, len(errs), errs)
+
+	child := children.Child()
+	a.NoError(child.Add(func(i testInterface2) testInterface1 {
+		return testOrderCloser1{testOrderCloser: testOrderCloser{name: "child", order: &order}}
+	}))
+	childAccessor, errs := child.BuildOnly()
+	a.Equal(0, len(errs), errs)
+	_, err := Get[testInterface1](childAccessor)
+	a.NoError(err)
+	_, err = Get[testInterface2](childAccessor)
+	a.NoError(err)
+	_, err = Get[testInterface1](parentAccessor)
+	a.Error(err)
+
+	a.Equal(0, len(childAccessor.Close()))
+	a.Equal("child", strings.Join(order, " "))
+	a.Equal(0, len(parentAccessor.Close()))
+	a.Equal("child parent", strings.Join(order, " "))
+}
+
+func TestChildInMain(t *testing.T) {
+	a := assert.New(t)
+
+	var order []string
+	var childErrs []error
+	r := New()
+	a.NoError(r.Add(func() testInterface2 {
+		return testOrderCloser{name: "parent", order: &order}
+	}))
+	a.NoError(r.AddLazy(new3Consume2))
+	a.NoError(r.Add(func(i testInterface2, children ChildRunners) Main {
+		return testMainFunc(func() error {
+			child := children.Child()
+			a.NoError(child.Add(func(i2 testInterface2, i3 testInterface3) testInterface1 {
+				return testOrderCloser1{testOrderCloser: testOrderCloser{name: "child", order: &order}}
+			}))
+			accessor, errs := child.BuildOnly()
+			childErrs = errs
+			if accessor != nil {
+				childErrs = append(childErrs, accessor.Close()...)
+			}
+			return nil
+		})
+	}))
+	errs := r.Run()
+	a.Equal(0, len(errs), errs)
+	a.Equal(0, len(childErrs), childErrs)
+	a.Equal("child parent", strings.Join(order, " "))
+}
+
+//********************
+func TestFailOnUnused(t *testing.T) {
+	a := assert.New(t)
+
+	r := New()
+	a.NoError(r.SetFailOnUnused())
+	a.NoError(r.Add(new2))
+	a.NoError(r.Add(new1Consume2))
+	a.NoError(r.Add(new3Consume2))
+	a.NoError(r.Add(newMain))
+	errs := r.Run()
+	a.Equal(1, len(errs), errs)
+	a.True(errors.Is(errs[0], ErrUnused), errs)
+	a.ErrorContains(errs[0], "runner.testInterface3")
+	a.False(strings.Contains(errs[0].Error(), "runner.testInterface2"), errs)
+
+	r = New()
+	a.NoError(r.SetFailOnUnused((*testInterface3)(nil)))
+	a.NoError(r.Add(new2))
+	a.NoError(r.Add(new1Consume2))
+	a.NoError(r.Add(new3Consume2))
+	a.NoError(r.Add(newMain))
+	errs = r.Run()
+	a.Equal(0, len(errs), errs)
+
+	a.True(errors.Is(New().SetFailOnUnused(testStruct3{}), ErrNotInterfacePointer))
+}
+
+func TestFailOnUnusedRoots(t *testing.T) {
+	a := assert.New(t)
+
+	// a service that is its own Main
+	r := New()
+	a.NoError(r.SetFailOnUnused())
+	a.NoError(r.Add(new2))
+	a.NoError(r.Add(func(i testInterface2) testInterface3 { return testServiceMain{} }))
+	errs := r.Run()
+	a.Equal(1, len(errs), errs)
+	a.True(errors.Is(errs[0], errMainError), errs)
+
+	// Starters, Drainers and closers do something without being depended on
+	var order []string
+	r = New()
+	a.NoError(r.SetFailOnUnused())
+	a.NoError(r.Add(new2))
+	a.NoError(r.Add(func(i testInterface2) testInterface3 {
+		return testStarter{start: func() error { return nil }}
+	}))
+	a.NoError(r.Add(func() testInterface1 {
+		return testOrderCloser1{testOrderCloser: testOrderCloser{name: "closer", order: &order}}
+	}))
+	a.NoError(r.Add(func() Main { return testMain{} }))
+	errs = r.Run()
+	a.Equal(0, len(errs), errs)
+	a.Equal("closer", strings.Join(order, " "))
+}
+
+//********************
+func TestSeed(t *testing.T) {
+	a := assert.New(t)
+
+	var order []string
+	r := New()
+	a.NoError(r.Seed((*testInterface2)(nil), testOrderCloser{name: "seed", order: &order}))
+	a.NoError(r.SeedUnmanaged((*testInterface3)(nil), testOrderCloser3{
+		testOrderCloser: testOrderCloser{name: "unmanaged", order: &order},
+	}))
+	a.NoError(r.Add(func(i2 testInterface2, i3 testInterface3) testInterface1 {
+		return testOrderCloser1{testOrderCloser: testOrderCloser{name: "produced", order: &order}}
+	}))
+	a.NoError(r.Add(newMain))
+	errs := r.Run()
+	a.Equal(0, len(errs), errs)
+	a.Equal("produced seed", strings.Join(order, " "))
+
+	r = New()
+	a.True(errors.Is(r.Seed((*testInterface2)(nil), testStruct3{}), ErrSeedInvalid))
+	a.True(errors.Is(r.Seed((*testInterface2)(nil), nil), ErrSeedInvalid))
+	a.NoError(r.Seed((*testInterface2)(nil), testStruct2{}))
+	a.True(errors.Is(r.Seed((*testInterface2)(nil), testStruct2{}), ErrSeedConflict))
+	a.NoError(r.Add(new2))
+	_, errs = r.BuildOnly()
+	a.Equal(1, len(errs), errs)
+	a.True(errors.Is(errs[0], ErrSeedConflict), errs)
+}
+
+//********************
+type testStarter struct {
+	testStruct3
+	start func() error
+}
+
+func (r testStarter) Start(ctx context.Context) error { return r.start() }
+
+func TestStarter(t *testing.T) {
+	a := assert.New(t)
+
+	var order []string
+	newStarter := func(err error) func() testInterface3 {
+		return func() testInterface3 {
+			return testStarter{start: func() error {
+				order = append(order, "start")
+				return err
+			}}
+		}
+	}
+	newMainOrder := func(i testInterface3) Main {
+		return testMainFunc(func() error {
+			order = append(order, "main")
+			return nil
+		})
+	}
+	r := New()
+	a.NoError(r.Add(newStarter(nil)))
+	a.NoError(r.Add(newMainOrder))
+	errs := r.Run()
+	a.Equal(0, len(errs), errs)
+	a.Equal("start main", strings.Join(order, " "))
+
+	order = nil
+	errStart := errors.New("start error")
+	r = New()
+	a.NoError(r.Add(newStarter(errStart)))
+	a.NoError(r.Add(newMainOrder))
+	errs = r.Run()
+	a.Equal(1, len(errs), errs)
+	a.True(errors.Is(errs[0], errStart), errs)
+	a.Equal("start", strings.Join(order, " "))
+
+	// a Starter that waits for Main only works when they run concurrently
+	release := make(chan struct{})
+	r = New()
+	r.SetStartBeforeMain(false)
+	a.NoError(r.Add(func() testInterface3 {
+		return testStarter{start: func() error {
+			<-release
+			return errStart
+		}}
+	}))
+	a.NoError(r.Add(func(i testInterface3) Main {
+		return testMainFunc(func() error {
+			close(release)
+			return nil
+		})
+	}))
+	errs = r.Run()
+	a.Equal(1, len(errs), errs)
+	a.True(errors.Is(errs[0], errStart), errs)
+
+	// a failed Starter shuts down a running Main
+	logger := &testLogger{}
+	r = New()
+	r.SetLogger(logger)
+	r.SetStartBeforeMain(false)
+	a.NoError(r.Add(newStarter(errStart)))
+	a.NoError(r.Add(func(i testInterface3) ContextMain { return testContextMain{} }))
+	errs = r.Run()
+	a.Equal(1, len(errs), errs)
+	a.True(errors.Is(errs[0], errStart), errs)
+	a.Equal(1, len(logger.lines))
+	a.True(strings.Contains(logger.lines[0], "starting failed"), logger.lines)
+	a.True(r.Timings().ShutdownRequested.IsZero())
+
+	// a plain Main can not be told to shutdown so it is waited for, even past the grace
+	r = New()
+	r.SetStartBeforeMain(false)
+	r.SetShutdownGrace(time.Millisecond)
+	a.NoError(r.Add(newStarter(errStart)))
+	a.NoError(r.Add(func(i testInterface3) Main {
+		return testMainFunc(func() error {
+			time.Sleep(20 * time.Millisecond)
+			return nil
+		})
+	}))
+	errs = r.Run()
+	a.Equal(1, len(errs), errs)
+	a.True(errors.Is(errs[0], errStart), errs)
+	a.True(r.Timings().ShutdownRequested.IsZero())
+}
+
+//********************
+type testConditionalCloser struct {
+	testStruct2
+	outcomes *[]bool
+}
+
+func (r testConditionalCloser) Close(success bool) error {
+	*r.outcomes = append(*r.outcomes, success)
+	return nil
+}
+
+func TestConditionalCloser(t *testing.T) {
+	a := assert.New(t)
+
+	var outcomes []bool
+	newCloser := func() testInterface2 { return testConditionalCloser{outcomes: &outcomes} }
+	r := New()
+	a.NoError(r.Add(newCloser))
+	a.NoError(r.Add(new1Consume2))
+	a.NoError(r.Add(newMain))
+	errs := r.Run()
+	a.Equal(0, len(errs), errs)
+
+	r = New()
+	a.NoError(r.Add(newCloser))
+	a.NoError(r.Add(func(i testInterface2) Main { return newMainError() }))
+	errs = r.Run()
+	a.Equal(1, len(errs), errs)
+
+	r = New()
+	a.NoError(r.Add(newCloser))
+	a.NoError(r.Add(func(i testInterface2) (testInterface1, error) {
+		return nil, errors.New("failed")
+	}))
+	_, errs = r.BuildOnly()
+	a.Equal(1, len(errs), errs)
+
+	a.Equal(3, len(outcomes))
+	a.True(outcomes[0])
+	a.False(outcomes[1])
+	a.False(outcomes[2])
+}
+
+//********************
+
+func TestSetQuietBugs(t *testing.T) {
+	if panicOnBug {
+		t.Skip("invariant violations panic with the runnerdebug tag")
+	}
+	a := assert.New(t)
+
+	logger := &testLogger{}
+	r := New().(*runner)
+	r.SetLogger(logger)
+	errs := r.bug(nil, "invariant broken")
+	a.Equal(1, len(errs), errs)
+	a.ErrorContains(errs[0], "invariant broken")
+	a.Equal(0, len(logger.lines), logger.lines)
+
+	r.SetQuietBugs(true)
+	errs = r.bug(nil, "invariant broken")
+	a.Equal(0, len(errs), errs)
+	a.Equal(1, len(logger.lines), logger.lines)
+	a.Equal("runner: invariant broken", logger.lines[0])
+
+	r.SetQuietBugs(false)
+	errs = r.bug(errs, "invariant broken again")
+	a.Equal(1, len(errs), errs)
+	a.Equal(1, len(logger.lines), logger.lines)
+}
@@ -0,0 +1,99 @@
+// Package runnertest provides test doubles for components that are wired by runner
+package runnertest
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Shutdowner is a fake general.Shutdowner that records the errors it is called with
+type Shutdowner struct {
+	lock   sync.Mutex
+	errs   []error
+	called chan struct{}
+}
+
+// NewShutdowner creates a Shutdowner that has not been called
+func NewShutdowner() *Shutdowner {
+	return &Shutdowner{called: make(chan struct{})}
+}
+
+// Shutdown records err, it never blocks
+func (r *Shutdowner) Shutdown(err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if len(r.errs) == 0 {
+		close(r.called)
+	}
+	r.errs = append(r.errs, err)
+}
+
+// Called returns a channel that is closed the first time Shutdown is called
+func (r *Shutdowner) Called() <-chan struct{} {
+	return r.called
+}
+
+// Errors returns the errors Shutdown was called with in call order
+func (r *Shutdowner) Errors() []error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]error(nil), r.errs...)
+}
+
+// AssertShutdown fails t unless Shutdown is called within timeout and the first call was with an
+// error matching err (using errors.Is, so nil only matches nil)
+func (r *Shutdowner) AssertShutdown(t testing.TB, err error, timeout time.Duration) {
+	t.Helper()
+	select {
+	case <-r.called:
+	case <-time.After(timeout):
+		t.Fatalf("Shutdown not called within %v", timeout)
+		return
+	}
+	first := r.Errors()[0]
+	if !errors.Is(first, err) {
+		t.Fatalf("Shutdown called with %v, expected %v", first, err)
+	}
+}
+
+// AssertNoShutdown fails t if Shutdown has been called
+func (r *Shutdowner) AssertNoShutdown(t testing.TB) {
+	t.Helper()
+	errs := r.Errors()
+	if len(errs) > 0 {
+		t.Fatalf("Shutdown called with %v", errs)
+	}
+}
+
+// Main is a fake runner.Main whose Run blocks until Return is called
+type Main struct {
+	started  chan struct{}
+	once     sync.Once
+	returned chan error
+}
+
+// NewMain creates a Main that has not been run
+func NewMain() *Main {
+	return &Main{
+		started:  make(chan struct{}),
+		returned: make(chan error, 1),
+	}
+}
+
+// Run see runner.Main interface doc, it returns the error passed to Return
+func (r *Main) Run() error {
+	r.once.Do(func() { close(r.started) })
+	return <-r.returned
+}
+
+// Started returns a channel that is closed once Run has been called
+func (r *Main) Started() <-chan struct{} {
+	return r.started
+}
+
+// Return makes Run return err, it must only be called once
+func (r *Main) Return(err error) {
+	r.returned <- err
+}
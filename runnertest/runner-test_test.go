@@ -0,0 +1,98 @@
+package runnertest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/blbgo/testing/assert"
+)
+
+//********************
+// fakeTB records the failures of an assertion helper instead of failing the test
+type fakeTB struct {
+	testing.TB
+	failures []string
+}
+
+func (r *fakeTB) Helper() {}
+
+func (r *fakeTB) Fatalf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+var errTest = errors.New("test")
+
+func TestShutdowner(t *testing.T) {
+	a := assert.New(t)
+
+	r := NewShutdowner()
+	select {
+	case <-r.Called():
+		t.Fatal("Called closed before Shutdown")
+	default:
+	}
+	r.Shutdown(errTest)
+	r.Shutdown(nil)
+	<-r.Called()
+	errs := r.Errors()
+	a.Equal(2, len(errs))
+	a.True(errors.Is(errs[0], errTest))
+	a.Nil(errs[1])
+}
+
+func TestAssertShutdown(t *testing.T) {
+	a := assert.New(t)
+
+	r := NewShutdowner()
+	go r.Shutdown(fmt.Errorf("wrapped: %w", errTest))
+	tb := &fakeTB{}
+	r.AssertShutdown(tb, errTest, time.Second)
+	a.Equal(0, len(tb.failures), tb.failures)
+
+	tb = &fakeTB{}
+	r.AssertShutdown(tb, nil, time.Second)
+	a.Equal(1, len(tb.failures), tb.failures)
+	a.Equal("Shutdown called with wrapped: test, expected <nil>", tb.failures[0])
+
+	tb = &fakeTB{}
+	NewShutdowner().AssertShutdown(tb, nil, time.Millisecond)
+	a.Equal(1, len(tb.failures), tb.failures)
+	a.Equal("Shutdown not called within 1ms", tb.failures[0])
+}
+
+func TestAssertNoShutdown(t *testing.T) {
+	a := assert.New(t)
+
+	r := NewShutdowner()
+	tb := &fakeTB{}
+	r.AssertNoShutdown(tb)
+	a.Equal(0, len(tb.failures), tb.failures)
+
+	r.Shutdown(errTest)
+	r.AssertNoShutdown(tb)
+	a.Equal(1, len(tb.failures), tb.failures)
+	a.Equal("Shutdown called with [test]", tb.failures[0])
+}
+
+//********************
+func TestFakeMain(t *testing.T) {
+	a := assert.New(t)
+
+	r := NewMain()
+	returned := make(chan error)
+	go func() { returned <- r.Run() }()
+	select {
+	case <-r.Started():
+	case <-time.After(time.Second):
+		t.Fatal("Started not closed once Run was called")
+	}
+	select {
+	case <-returned:
+		t.Fatal("Run returned before Return was called")
+	default:
+	}
+	r.Return(errTest)
+	a.True(errors.Is(<-returned, errTest))
+}
@@ -0,0 +1,74 @@
+package runner
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// seed is a value given to Seed or SeedUnmanaged
+type seed struct {
+	valueType reflect.Type
+	value     reflect.Value
+	// unmanaged seeds are not closed by the runner
+	unmanaged bool
+}
+
+// Seed see Runner interface doc
+func (r *runner) Seed(interfacePtr interface{}, value interface{}) error {
+	return r.addSeed(interfacePtr, value, false)
+}
+
+// SeedUnmanaged see Runner interface doc
+func (r *runner) SeedUnmanaged(interfacePtr interface{}, value interface{}) error {
+	return r.addSeed(interfacePtr, value, true)
+}
+
+// addSeed validates value and saves it to be provided as the interface interfacePtr points to
+func (r *runner) addSeed(interfacePtr interface{}, value interface{}, unmanaged bool) error {
+	valueType, err := interfaceType(interfacePtr)
+	if err != nil {
+		return err
+	}
+	if value == nil || !reflect.TypeOf(value).Implements(valueType) {
+		return fmt.Errorf("%w type: %v got: %T", ErrSeedInvalid, valueType, value)
+	}
+	if r.seeded(valueType) {
+		return fmt.Errorf("%w type: %v", ErrSeedConflict, valueType)
+	}
+	seedValue := reflect.New(valueType).Elem()
+	seedValue.Set(reflect.ValueOf(value))
+	r.seeds = append(r.seeds, seed{valueType: valueType, value: seedValue, unmanaged: unmanaged})
+	return nil
+}
+
+// seeded reports whether a value of valueType was seeded
+func (r *runner) seeded(valueType reflect.Type) bool {
+	for _, s := range r.seeds {
+		if s.valueType == valueType {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSeeds returns an error for each seeded type that something else also provides
+func (r *runner) checkSeeds() []error {
+	var errs []error
+	for _, s := range r.seeds {
+		if builtinTypes[s.valueType] || r.canProvide(s.valueType) {
+			errs = append(errs, fmt.Errorf("%w type: %v", ErrSeedConflict, s.valueType))
+		}
+	}
+	return errs
+}
+
+// provideSeeds puts the seeded values into values, they are registered to be closed first so
+// they are closed after everything the producers make
+func (r *runner) provideSeeds() {
+	for _, s := range r.seeds {
+		r.values[s.valueType] = s.value
+		if !s.unmanaged {
+			r.saveIfCloser(s.value, 0)
+		}
+	}
+}
@@ -1,47 +1,228 @@
-package shutdownermain
-
-import (
-	"sync"
-
-	"github.com/blbgo/general"
-	"github.com/blbgo/runner"
-)
-
-type shutdowner struct {
-	sync.Mutex
-	done         bool
-	shutdownChan chan error
-}
-
-type main <-chan error
-
-// NewShutdownerMain provides general.Shutdowner and runner.Main
-func NewShutdownerMain() (general.Shutdowner, runner.Main) {
-	r := &shutdowner{
-		shutdownChan: make(chan error),
-	}
-	return r, main(r.shutdownChan)
-}
-
-// **************** implement general.Shutdowner on shutdowner
-
-// Shutdown tells the runner stack to shutdown (the Main.Run method will return). An error can
-// be provided that will be returned by Main.Run (first call to Shutdown only).  nil can be
-// provided to cause Main.Run to return nil.
-func (r *shutdowner) Shutdown(err error) {
-	r.Lock()
-	defer r.Unlock()
-	if !r.done {
-		r.done = true
-		r.shutdownChan <- err
-		close(r.shutdownChan)
-	}
-}
-
-// **************** implement runner.Main on main
-
-// Run waits for somthing (an error or nil) to come through the channel and then returns it
-func (r main) Run() error {
-	// wait for first sent item and return it
-	return <-r
-}
+package shutdownermain
+
+import (
+	"sync"
+	"time"
+
+	"github.com/blbgo/general"
+	"github.com/blbgo/runner"
+)
+
+// Status allows the reason for a shutdown to be queried after the fact
+type Status interface {
+	// Reason returns the error provided to the first call to Shutdown, nil if Shutdown has not
+	// been called or was called with nil.
+	Reason() error
+	// Requested reports whether Shutdown has been called, so a clean Shutdown(nil) can be told
+	// apart from never having shut down.
+	Requested() bool
+}
+
+// ShutdownSource is implemented by modules that decide on their own when the system should shut
+// down. All produced ShutdownSource values are watched by the Main from NewShutdownerMainFanIn.
+type ShutdownSource interface {
+	// ShutdownRequested returns a channel that receives the shutdown error or is closed to
+	// request shutdown with a nil error
+	ShutdownRequested() <-chan error
+}
+
+type shutdowner struct {
+	sync.Mutex
+	// shutdownChan is buffered so Shutdown never blocks, even if Run has returned or was never
+	// called
+	shutdownChan chan error
+	reason       error
+	requested    bool
+}
+
+type main <-chan error
+
+type fanInMain struct {
+	*shutdowner
+	sources []ShutdownSource
+}
+
+// NewShutdownerMain provides general.Shutdowner and runner.Main
+func NewShutdownerMain() (general.Shutdowner, runner.Main) {
+	r := &shutdowner{
+		shutdownChan: make(chan error, 1),
+	}
+	return r, main(r.shutdownChan)
+}
+
+// NewShutdownerMainWithStatus provides general.Shutdowner, runner.Main and Status so that
+// components other than the caller of Main.Run can learn why the system shut down.
+func NewShutdownerMainWithStatus() (general.Shutdowner, runner.Main, Status) {
+	r := &shutdowner{
+		shutdownChan: make(chan error, 1),
+	}
+	return r, main(r.shutdownChan), r
+}
+
+// NewShutdownerMainFanIn provides a single general.Shutdowner and runner.Main shared by all
+// modules. The Main returns when the general.Shutdowner is called or when any of the provided
+// sources requests shutdown, whichever happens first.
+func NewShutdownerMainFanIn(sources []ShutdownSource) (general.Shutdowner, runner.Main) {
+	r := &shutdowner{
+		shutdownChan: make(chan error, 1),
+	}
+	return r, &fanInMain{shutdowner: r, sources: sources}
+}
+
+// **************** implement general.Shutdowner on shutdowner
+
+// Shutdown tells the runner stack to shutdown (the Main.Run method will return). An error can
+// be provided that will be returned by Main.Run (first call to Shutdown only).  nil can be
+// provided to cause Main.Run to return nil.  It never blocks.
+func (r *shutdowner) Shutdown(err error) {
+	r.Lock()
+	defer r.Unlock()
+	if !r.requested {
+		r.requested = true
+		r.reason = err
+		r.shutdownChan <- err
+		close(r.shutdownChan)
+	}
+}
+
+// **************** implement Status on shutdowner
+
+// Reason returns the error the first call to Shutdown was given
+func (r *shutdowner) Reason() error {
+	r.Lock()
+	defer r.Unlock()
+	return r.reason
+}
+
+// Requested returns whether Shutdown has been called
+func (r *shutdowner) Requested() bool {
+	r.Lock()
+	defer r.Unlock()
+	return r.requested
+}
+
+// **************** implement runner.Main on main
+
+// Run waits for somthing (an error or nil) to come through the channel and then returns it
+func (r main) Run() error {
+	// wait for first sent item and return it
+	return <-r
+}
+
+// **************** implement runner.Main on fanInMain
+
+// Run forwards the first request from any source to Shutdown and returns the first error sent
+// to Shutdown
+func (r *fanInMain) Run() error {
+	stopChan := make(chan struct{})
+	defer close(stopChan)
+	for _, source := range r.sources {
+		go r.forward(source.ShutdownRequested(), stopChan)
+	}
+	return <-r.shutdownChan
+}
+
+func (r *fanInMain) forward(requestChan <-chan error, stopChan <-chan struct{}) {
+	select {
+	case err := <-requestChan:
+		r.Shutdown(err)
+	case <-stopChan:
+	}
+}
+
+// Reducer decides the shutdown error when Shutdown is called more than once, it is given the
+// error decided so far and the error from the latest call
+type Reducer func(current, next error) error
+
+// KeepFirst is a Reducer that keeps the error from the first call to Shutdown
+func KeepFirst(current, next error) error { return current }
+
+// KeepLast is a Reducer that keeps the error from the latest call to Shutdown
+func KeepLast(current, next error) error { return next }
+
+// KeepFirstError is a Reducer that keeps the first non nil error, so a later failure replaces
+// an earlier clean Shutdown(nil)
+func KeepFirstError(current, next error) error {
+	if current != nil {
+		return current
+	}
+	return next
+}
+
+type reducingShutdowner struct {
+	lock         sync.Mutex
+	reduce       Reducer
+	window       time.Duration
+	shutdownChan chan struct{}
+	reason       error
+	requested    bool
+	read         bool
+}
+
+// NewShutdownerMainReducing provides general.Shutdowner, runner.Main and Status like
+// NewShutdownerMainWithStatus except that Shutdown never blocks and may be called repeatedly.
+// Once Shutdown is first called Main.Run waits for window before returning, each call made
+// until then updates the error using reduce.  Later calls are ignored, so with a zero window
+// only calls made before Main.Run wakes (always the first) are reduced.
+func NewShutdownerMainReducing(
+	reduce Reducer,
+	window time.Duration,
+) (general.Shutdowner, runner.Main, Status) {
+	r := &reducingShutdowner{
+		reduce:       reduce,
+		window:       window,
+		shutdownChan: make(chan struct{}),
+	}
+	return r, r, r
+}
+
+// **************** implement general.Shutdowner on reducingShutdowner
+
+// Shutdown tells the runner stack to shutdown, the first call makes Main.Run return once the
+// window passes and the error it returns is reduced from the calls made until it does
+func (r *reducingShutdowner) Shutdown(err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.read {
+		return
+	}
+	if !r.requested {
+		r.requested = true
+		r.reason = err
+		close(r.shutdownChan)
+		return
+	}
+	r.reason = r.reduce(r.reason, err)
+}
+
+// **************** implement Status on reducingShutdowner
+
+// Reason returns the error reduced from the calls to Shutdown so far
+func (r *reducingShutdowner) Reason() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.reason
+}
+
+// Requested returns whether Shutdown has been called
+func (r *reducingShutdowner) Requested() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.requested
+}
+
+// **************** implement runner.Main on reducingShutdowner
+
+// Run waits for the first call to Shutdown and the window after it, then returns the reduced
+// error, which is then final
+func (r *reducingShutdowner) Run() error {
+	<-r.shutdownChan
+	if r.window > 0 {
+		timer := time.NewTimer(r.window)
+		<-timer.C
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.read = true
+	return r.reason
+}
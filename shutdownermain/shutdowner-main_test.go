@@ -0,0 +1,153 @@
+package shutdownermain
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/blbgo/testing/assert"
+)
+
+//********************
+func TestShutdownAfterRunReturned(t *testing.T) {
+	a := assert.New(t)
+
+	shutdowner, main, status := NewShutdownerMainWithStatus()
+	errFirst := errors.New("first")
+	shutdowner.Shutdown(errFirst)
+	a.True(errors.Is(main.Run(), errFirst))
+
+	called := make(chan struct{})
+	go func() {
+		shutdowner.Shutdown(errors.New("second"))
+		close(called)
+	}()
+	select {
+	case <-called:
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown blocked after Run returned")
+	}
+	a.True(errors.Is(status.Reason(), errFirst))
+	a.True(status.Requested())
+}
+
+//********************
+func TestShutdownWithoutRun(t *testing.T) {
+	shutdowner, _ := NewShutdownerMain()
+	called := make(chan struct{})
+	go func() {
+		shutdowner.Shutdown(nil)
+		shutdowner.Shutdown(nil)
+		close(called)
+	}()
+	select {
+	case <-called:
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown blocked when Run was never called")
+	}
+}
+
+//********************
+func TestShutdownerMainReducing(t *testing.T) {
+	a := assert.New(t)
+
+	errFirst := errors.New("first")
+	errLater := errors.New("later")
+	for _, test := range []struct {
+		reduce Reducer
+		want   error
+	}{
+		{KeepFirst, nil},
+		{KeepLast, errLater},
+		{KeepFirstError, errFirst},
+	} {
+		// calls made before Run are always reduced
+		shutdowner, main, status := NewShutdownerMainReducing(test.reduce, 0)
+		shutdowner.Shutdown(nil)
+		shutdowner.Shutdown(errFirst)
+		shutdowner.Shutdown(errLater)
+		a.True(status.Requested())
+		a.Equal(test.want, main.Run())
+		shutdowner.Shutdown(errors.New("after Run"))
+		a.Equal(test.want, status.Reason())
+	}
+}
+
+//********************
+func TestShutdownerMainReducingWindow(t *testing.T) {
+	a := assert.New(t)
+
+	errLater := errors.New("later")
+	shutdowner, main, status := NewShutdownerMainReducing(KeepFirstError, 100*time.Millisecond)
+	done := make(chan error, 1)
+	go func() { done <- main.Run() }()
+	shutdowner.Shutdown(nil)
+	// Run is waiting out the window so this call still counts
+	time.Sleep(10 * time.Millisecond)
+	shutdowner.Shutdown(errLater)
+	select {
+	case err := <-done:
+		a.True(errors.Is(err, errLater), err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the window")
+	}
+	shutdowner.Shutdown(errors.New("after Run"))
+	a.True(errors.Is(status.Reason(), errLater))
+}
+
+//********************
+type testSource chan error
+
+func (r testSource) ShutdownRequested() <-chan error { return r }
+
+// waitForGoroutines waits for the number of goroutines to drop to count, reporting whether it did
+func waitForGoroutines(count int) bool {
+	for i := 0; i < 100; i++ {
+		if runtime.NumGoroutine() <= count {
+			return true
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return false
+}
+
+func TestShutdownerMainFanIn(t *testing.T) {
+	a := assert.New(t)
+
+	// any source requesting shutdown makes Run return its error
+	errSource := errors.New("source")
+	quiet := make(testSource)
+	firing := make(testSource, 1)
+	firing <- errSource
+	_, main := NewShutdownerMainFanIn([]ShutdownSource{quiet, firing})
+	a.True(errors.Is(main.Run(), errSource))
+
+	// a closed source requests shutdown with a nil error
+	closed := make(testSource)
+	close(closed)
+	_, main = NewShutdownerMainFanIn([]ShutdownSource{quiet, closed})
+	a.NoError(main.Run())
+}
+
+func TestShutdownerMainFanInNilSource(t *testing.T) {
+	a := assert.New(t)
+
+	// a source with a nil channel never requests shutdown, the Shutdowner still works
+	errShutdown := errors.New("shutdown")
+	shutdowner, main := NewShutdownerMainFanIn([]ShutdownSource{testSource(nil)})
+	go shutdowner.Shutdown(errShutdown)
+	a.True(errors.Is(main.Run(), errShutdown))
+}
+
+func TestShutdownerMainFanInNoLeak(t *testing.T) {
+	a := assert.New(t)
+
+	before := runtime.NumGoroutine()
+	shutdowner, main := NewShutdownerMainFanIn(
+		[]ShutdownSource{make(testSource), testSource(nil), make(testSource)},
+	)
+	shutdowner.Shutdown(nil)
+	a.NoError(main.Run())
+	a.True(waitForGoroutines(before), "forward goroutines still running after Run returned")
+}
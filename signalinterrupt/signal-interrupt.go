@@ -1,54 +1,137 @@
-package signalinterrupt
-
-import (
-	"errors"
-	"os"
-	"os/signal"
-
-	"github.com/blbgo/general"
-)
-
-type signalInterrupt struct {
-	general.Shutdowner
-	signalChan chan os.Signal
-	doneChan   chan<- error
-}
-
-// NewSignalInterrupt creates a signalInterrupt and returns it as a general.DelayCloser. This
-// allows ctrl-C to cleanly shutdown a command line program.
-func NewSignalInterrupt(shutdowner general.Shutdowner) general.DelayCloser {
-	r := &signalInterrupt{
-		Shutdowner: shutdowner,
-		signalChan: make(chan os.Signal, 1),
-	}
-
-	signal.Notify(r.signalChan, os.Interrupt)
-
-	go r.run()
-
-	return r
-}
-
-func (r *signalInterrupt) Close(doneChan chan<- error) {
-	r.doneChan = doneChan
-
-	signal.Stop(r.signalChan)
-	close(r.signalChan)
-}
-
-func (r *signalInterrupt) run() {
-	// wait for signal or chanel close
-	_, ok := <-r.signalChan
-
-	// got signal?
-	if ok {
-		r.Shutdown(errors.New("Interrupt signal received"))
-	}
-
-	// wait for chanel to close
-	for ok {
-		_, ok = <-r.signalChan
-	}
-
-	r.doneChan <- nil
-}
+package signalinterrupt
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+
+	"github.com/blbgo/general"
+	"github.com/blbgo/runner"
+)
+
+// ErrInterrupt is the error passed to Shutdown when an interrupt signal is received, it matches
+// runner.ErrShutdownSignal with errors.Is
+var ErrInterrupt error = interruptError{}
+
+type interruptError struct{}
+
+func (interruptError) Error() string { return "Interrupt signal received" }
+
+func (interruptError) Is(target error) bool { return target == runner.ErrShutdownSignal }
+
+// notify and stopNotify register and unregister the channel interrupt signals are sent to, tests
+// replace them so no real signals are needed
+var notify = signal.Notify
+var stopNotify = signal.Stop
+
+// Pauser allows interrupt handling to be suspended during critical sections
+type Pauser interface {
+	// Pause causes interrupt signals to be ignored until Resume is called
+	Pause()
+	// Resume causes interrupt signals to trigger shutdown again
+	Resume()
+}
+
+type signalInterrupt struct {
+	general.Shutdowner
+	ctx        context.Context
+	signalChan chan os.Signal
+	closeChan  chan chan<- error
+	stopped    chan struct{}
+
+	pauseLock sync.Mutex
+	paused    bool
+}
+
+// NewSignalInterrupt creates a signalInterrupt and returns it as a general.DelayCloser. This
+// allows ctrl-C to cleanly shutdown a command line program.
+func NewSignalInterrupt(shutdowner general.Shutdowner) general.DelayCloser {
+	return newSignalInterrupt(context.Background(), shutdowner)
+}
+
+// NewSignalInterruptPausable is like NewSignalInterrupt but also provides a Pauser so interrupts
+// can be ignored while something that must not be aborted is in progress.
+func NewSignalInterruptPausable(shutdowner general.Shutdowner) (general.DelayCloser, Pauser) {
+	r := newSignalInterrupt(context.Background(), shutdowner)
+	return r, r
+}
+
+// NewSignalInterruptContext is like NewSignalInterrupt but interrupt handling also stops when ctx
+// is done, so it can be torn down without calling Close.  Close may still be called after that.
+func NewSignalInterruptContext(
+	ctx context.Context,
+	shutdowner general.Shutdowner,
+) general.DelayCloser {
+	return newSignalInterrupt(ctx, shutdowner)
+}
+
+func newSignalInterrupt(ctx context.Context, shutdowner general.Shutdowner) *signalInterrupt {
+	r := &signalInterrupt{
+		Shutdowner: shutdowner,
+		ctx:        ctx,
+		signalChan: make(chan os.Signal, 1),
+		closeChan:  make(chan chan<- error),
+		stopped:    make(chan struct{}),
+	}
+
+	notify(r.signalChan, os.Interrupt)
+
+	go r.run()
+
+	return r
+}
+
+func (r *signalInterrupt) Close(doneChan chan<- error) {
+	select {
+	case r.closeChan <- doneChan:
+	case <-r.stopped:
+		// already stopped by ctx, still report done without blocking the caller
+		go func() { doneChan <- nil }()
+	}
+}
+
+// Pause see Pauser interface doc
+func (r *signalInterrupt) Pause() {
+	r.pauseLock.Lock()
+	defer r.pauseLock.Unlock()
+	r.paused = true
+}
+
+// Resume see Pauser interface doc
+func (r *signalInterrupt) Resume() {
+	r.pauseLock.Lock()
+	defer r.pauseLock.Unlock()
+	r.paused = false
+}
+
+func (r *signalInterrupt) isPaused() bool {
+	r.pauseLock.Lock()
+	defer r.pauseLock.Unlock()
+	return r.paused
+}
+
+func (r *signalInterrupt) run() {
+	defer close(r.stopped)
+	defer stopNotify(r.signalChan)
+
+	// wait for signals until closed or ctx is done, signals are still received while paused (so
+	// the default handler does not kill the process) but are dropped
+	shutdown := false
+	for {
+		select {
+		case <-r.signalChan:
+			if shutdown || r.isPaused() {
+				continue
+			}
+			shutdown = true
+			r.Shutdown(ErrInterrupt)
+		case doneChan := <-r.closeChan:
+			stopNotify(r.signalChan)
+			doneChan <- nil
+			return
+		case <-r.ctx.Done():
+			return
+		}
+	}
+}
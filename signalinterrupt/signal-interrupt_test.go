@@ -0,0 +1,105 @@
+package signalinterrupt
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"testing"
+	"time"
+
+	"github.com/blbgo/testing/assert"
+)
+
+//********************
+type testShutdowner chan error
+
+func (r testShutdowner) Shutdown(err error) { r <- err }
+
+// fakeNotify replaces notify and stopNotify so signals are only those the test sends, the
+// returned func restores them
+func fakeNotify() func() {
+	notify = func(c chan<- os.Signal, sig ...os.Signal) {}
+	stopNotify = func(c chan<- os.Signal) {}
+	return func() {
+		notify = signal.Notify
+		stopNotify = signal.Stop
+	}
+}
+
+// noShutdown reports whether shutdowner was not called within a short wait
+func noShutdown(shutdowner testShutdowner) bool {
+	select {
+	case <-shutdowner:
+		return false
+	case <-time.After(20 * time.Millisecond):
+		return true
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	a := assert.New(t)
+	defer fakeNotify()()
+
+	shutdowner := make(testShutdowner, 2)
+	closer, pauser := NewSignalInterruptPausable(shutdowner)
+	r := closer.(*signalInterrupt)
+
+	pauser.Pause()
+	r.signalChan <- os.Interrupt
+	a.True(noShutdown(shutdowner), "interrupt while paused requested shutdown")
+
+	pauser.Resume()
+	r.signalChan <- os.Interrupt
+	select {
+	case err := <-shutdowner:
+		a.True(errors.Is(err, ErrInterrupt), err)
+	case <-time.After(time.Second):
+		t.Fatal("interrupt after Resume did not request shutdown")
+	}
+
+	// only the first interrupt requests shutdown
+	r.signalChan <- os.Interrupt
+	a.True(noShutdown(shutdowner), "second interrupt requested shutdown again")
+
+	doneChan := make(chan error)
+	r.Close(doneChan)
+	a.NoError(<-doneChan)
+}
+
+//********************
+func TestContextDoneStops(t *testing.T) {
+	a := assert.New(t)
+	defer fakeNotify()()
+
+	var registered, unregistered chan<- os.Signal
+	notify = func(c chan<- os.Signal, sig ...os.Signal) { registered = c }
+	stopped := make(chan struct{})
+	stopNotify = func(c chan<- os.Signal) {
+		unregistered = c
+		close(stopped)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	shutdowner := make(testShutdowner, 1)
+	closer := NewSignalInterruptContext(ctx, shutdowner)
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("signal handler not unregistered once ctx was done")
+	}
+	a.NotNil(registered)
+	a.True(registered == unregistered, "a different channel was unregistered")
+	a.True(noShutdown(shutdowner), "ctx done requested shutdown")
+
+	// Close still reports done once ctx stopped the handler
+	doneChan := make(chan error)
+	closer.Close(doneChan)
+	select {
+	case err := <-doneChan:
+		a.NoError(err)
+	case <-time.After(time.Second):
+		t.Fatal("Close did not report done after ctx stopped the handler")
+	}
+}
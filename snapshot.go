@@ -0,0 +1,48 @@
+package runner
+
+// TypeSnapshot is the names of the types a build provided, in the order they were first provided
+type TypeSnapshot []string
+
+// TypeDiff is the difference between two TypeSnapshots
+type TypeDiff struct {
+	// Added are the types only in the later snapshot
+	Added []string
+	// Removed are the types only in the earlier snapshot
+	Removed []string
+	// Retained are the types in both snapshots
+	Retained []string
+}
+
+// TypeSnapshot see Runner interface doc
+func (r *runner) TypeSnapshot() TypeSnapshot {
+	snapshot := make(TypeSnapshot, len(r.producedTypes))
+	for i, v := range r.producedTypes {
+		snapshot[i] = v.String()
+	}
+	return snapshot
+}
+
+// Diff compares r with later, a snapshot from a later build, for example after a reload.  Added
+// and Retained are in the order of later, Removed in the order of r.
+func (r TypeSnapshot) Diff(later TypeSnapshot) TypeDiff {
+	earlierSet := make(map[string]bool, len(r))
+	for _, v := range r {
+		earlierSet[v] = true
+	}
+	laterSet := make(map[string]bool, len(later))
+	var diff TypeDiff
+	for _, v := range later {
+		laterSet[v] = true
+		if earlierSet[v] {
+			diff.Retained = append(diff.Retained, v)
+		} else {
+			diff.Added = append(diff.Added, v)
+		}
+	}
+	for _, v := range r {
+		if !laterSet[v] {
+			diff.Removed = append(diff.Removed, v)
+		}
+	}
+	return diff
+}
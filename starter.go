@@ -0,0 +1,28 @@
+package runner
+
+import (
+	"context"
+	"reflect"
+)
+
+// SetStartBeforeMain see Runner interface doc
+func (r *runner) SetStartBeforeMain(before bool) {
+	r.startWithMain = !before
+}
+
+func (r *runner) saveIfStarter(value reflect.Value) {
+	starter, ok := value.Interface().(Starter)
+	if ok {
+		r.starters = append(r.starters, starter)
+	}
+}
+
+// start calls Start on each Starter in creation order, stopping at the first error
+func (r *runner) start(ctx context.Context) error {
+	for _, starter := range r.starters {
+		if err := starter.Start(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
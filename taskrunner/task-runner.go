@@ -0,0 +1,80 @@
+// Package taskrunner provides a component that runs managed background tasks
+package taskrunner
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/blbgo/general"
+)
+
+// ErrClosing indicates Go was called once Close had been called, the task was not run
+var ErrClosing = errors.New("task runner closing")
+
+// TaskRunner runs background tasks for producers.  Closing it cancels the context passed to the
+// tasks and waits for them all to return, the runner waits for that up to its close timeout.
+type TaskRunner interface {
+	general.DelayCloser
+	// Go runs task in a goroutine, ctx is done once the TaskRunner is closed.  Once Close has
+	// been called task is not run and ErrClosing is returned.
+	Go(task func(ctx context.Context) error) error
+}
+
+type taskRunner struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	lock   sync.Mutex
+	closed bool
+	err    error
+}
+
+// NewTaskRunner creates a TaskRunner with no tasks.  Close reports the first error a task
+// returned other than context.Canceled.
+func NewTaskRunner() TaskRunner {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &taskRunner{ctx: ctx, cancel: cancel}
+}
+
+// Go see TaskRunner interface doc
+func (r *taskRunner) Go(task func(ctx context.Context) error) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.closed {
+		return ErrClosing
+	}
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.setErr(task(r.ctx))
+	}()
+	return nil
+}
+
+func (r *taskRunner) setErr(err error) {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// Close see general.DelayCloser interface doc
+func (r *taskRunner) Close(doneChan chan<- error) {
+	r.lock.Lock()
+	r.closed = true
+	r.lock.Unlock()
+	r.cancel()
+	go func() {
+		r.wg.Wait()
+		r.lock.Lock()
+		err := r.err
+		r.lock.Unlock()
+		doneChan <- err
+	}()
+}
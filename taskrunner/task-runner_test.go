@@ -0,0 +1,52 @@
+package taskrunner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/blbgo/testing/assert"
+)
+
+//********************
+func TestCloseWaitsForTasks(t *testing.T) {
+	a := assert.New(t)
+
+	r := NewTaskRunner()
+	var stopped int32
+	for i := 0; i < 3; i++ {
+		a.NoError(r.Go(func(ctx context.Context) error {
+			<-ctx.Done()
+			atomic.AddInt32(&stopped, 1)
+			return fmt.Errorf("stopped: %w", ctx.Err())
+		}))
+	}
+	doneChan := make(chan error)
+	r.Close(doneChan)
+	a.NoError(<-doneChan)
+	a.Equal(int32(3), atomic.LoadInt32(&stopped))
+
+	err := r.Go(func(ctx context.Context) error {
+		t.Error("task started after Close")
+		return nil
+	})
+	a.True(errors.Is(err, ErrClosing))
+}
+
+//********************
+func TestCloseReportsTaskError(t *testing.T) {
+	a := assert.New(t)
+
+	errTask := errors.New("task failed")
+	r := NewTaskRunner()
+	r.Go(func(ctx context.Context) error { return errTask })
+	r.Go(func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+	doneChan := make(chan error)
+	r.Close(doneChan)
+	a.True(errors.Is(<-doneChan, errTask))
+}
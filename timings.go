@@ -0,0 +1,20 @@
+package runner
+
+import "time"
+
+// Timings are when the stages of a run happened, a stage that did not happen is the zero time
+type Timings struct {
+	// ShutdownRequested is when the context passed to RunContext was done while Main ran
+	ShutdownRequested time.Time
+	// MainReturned is when Main.Run returned (or was given up on after the shutdown grace)
+	MainReturned time.Time
+	// CloseStarted is when the close sequence started
+	CloseStarted time.Time
+	// CloseCompleted is when the close sequence completed, before OnClosed callbacks are called
+	CloseCompleted time.Time
+}
+
+// Timings see Runner interface doc
+func (r *runner) Timings() Timings {
+	return r.timings
+}
@@ -0,0 +1,106 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/blbgo/general"
+)
+
+// SetFailOnUnused see Runner interface doc
+func (r *runner) SetFailOnUnused(exemptPtrs ...interface{}) error {
+	for _, exemptPtr := range exemptPtrs {
+		valueType, err := interfaceType(exemptPtr)
+		if err != nil {
+			return err
+		}
+		r.unusedExempt[valueType] = true
+	}
+	r.failOnUnused = true
+	return nil
+}
+
+// checkUnused returns an error with the produced types that nothing depends on, directly or
+// through other producers, from a root.  The roots are the types of the Main findMain finds, the
+// exempt types and the types whose values do something without being depended on: Starters,
+// Drainers and values registered to be closed.
+func (r *runner) checkUnused() error {
+	producers := append([]*producer(nil), r.producers...)
+	for _, p := range r.lazy {
+		producers = append(producers, p)
+	}
+	for _, p := range r.factories {
+		producers = append(producers, p)
+	}
+	used := map[reflect.Type]bool{}
+	var use func(valueType reflect.Type)
+	use = func(valueType reflect.Type) {
+		switch {
+		case valueType.Kind() == reflect.Slice || valueType.Kind() == reflect.Map:
+			valueType = valueType.Elem()
+		case valueType.Kind() == reflect.Chan:
+			valueType = reflect.ChanOf(reflect.BothDir, valueType.Elem())
+		case isFactoryType(valueType):
+			valueType = valueType.Out(0)
+		}
+		if used[valueType] {
+			return
+		}
+		used[valueType] = true
+		for _, p := range producers {
+			if containsType(r.providedTypes(p.value.Type()), valueType) {
+				for _, inType := range dependencies(p.value.Type()) {
+					use(inType)
+				}
+			}
+		}
+	}
+	// with no Main (like from BuildOnly) only the other roots are used
+	_, mainTypes, _ := r.findMainTypes()
+	for _, valueType := range mainTypes {
+		use(valueType)
+	}
+	for _, valueType := range r.producedTypes {
+		if r.unusedExempt[valueType] || r.lifecycleType(valueType) {
+			use(valueType)
+		}
+	}
+	var unused []reflect.Type
+	for _, valueType := range r.producedTypes {
+		if !used[valueType] && !builtinTypes[valueType] {
+			unused = append(unused, valueType)
+		}
+	}
+	if len(unused) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w types: %v", ErrUnused, unused)
+}
+
+// lifecycleType reports whether a value provided as valueType is a Starter, a Drainer or was
+// registered to be closed
+func (r *runner) lifecycleType(valueType reflect.Type) bool {
+	values := []reflect.Value{r.values[valueType]}
+	if slice, ok := r.values[reflect.SliceOf(valueType)]; ok {
+		values = values[:0]
+		for i := 0; i < slice.Len(); i++ {
+			values = append(values, slice.Index(i))
+		}
+	}
+	for _, value := range values {
+		if !value.IsValid() {
+			continue
+		}
+		valueInterface := value.Interface()
+		switch valueInterface.(type) {
+		case Starter, Drainer:
+			return true
+		case io.Closer, general.DelayCloser, ConditionalCloser:
+			if !r.closeExcluded[valueType] && !r.closeExcluded[reflect.TypeOf(valueInterface)] {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+// Package waitgroup provides a general.DelayCloser that waits for background goroutines
+package waitgroup
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/blbgo/general"
+)
+
+// ErrClosing indicates Go was called once Close had been called, the goroutine was not started
+var ErrClosing = errors.New("wait group closing")
+
+// WaitGroup tracks background goroutines like sync.WaitGroup, closing it waits for them all to
+// finish before reporting done.  The runner waits for that up to its close timeout.
+type WaitGroup interface {
+	general.DelayCloser
+	// Add adds delta to the number of goroutines being waited for, like sync.WaitGroup it must
+	// not add to a count of zero once Close has been called, use Go when that could happen
+	Add(delta int)
+	// Done marks one goroutine as finished
+	Done()
+	// Go runs f in a goroutine that is waited for.  Once Close has been called f is not run and
+	// ErrClosing is returned.
+	Go(f func()) error
+	// Closing returns a channel that is closed when Close is called so goroutines know to stop
+	Closing() <-chan struct{}
+}
+
+type waitGroup struct {
+	sync.WaitGroup
+	// lock makes checking closed and adding to the count in Go one step Close can not come
+	// between, so Wait never runs alongside an Add from Go
+	lock    sync.Mutex
+	closed  bool
+	closing chan struct{}
+}
+
+// NewWaitGroup creates a WaitGroup with no goroutines
+func NewWaitGroup() WaitGroup {
+	return &waitGroup{closing: make(chan struct{})}
+}
+
+// Go see WaitGroup interface doc
+func (r *waitGroup) Go(f func()) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.closed {
+		return ErrClosing
+	}
+	r.Add(1)
+	go func() {
+		defer r.Done()
+		f()
+	}()
+	return nil
+}
+
+// Closing see WaitGroup interface doc
+func (r *waitGroup) Closing() <-chan struct{} {
+	return r.closing
+}
+
+// Close see general.DelayCloser interface doc
+func (r *waitGroup) Close(doneChan chan<- error) {
+	r.lock.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.closing)
+	}
+	r.lock.Unlock()
+	go func() {
+		r.Wait()
+		doneChan <- nil
+	}()
+}
@@ -0,0 +1,80 @@
+package waitgroup
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/blbgo/testing/assert"
+)
+
+//********************
+func TestCloseWaits(t *testing.T) {
+	a := assert.New(t)
+
+	wg := NewWaitGroup()
+	var finished int32
+	for i := 0; i < 4; i++ {
+		a.NoError(wg.Go(func() {
+			<-wg.Closing()
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&finished, 1)
+		}))
+	}
+	doneChan := make(chan error, 1)
+	wg.Close(doneChan)
+	select {
+	case err := <-doneChan:
+		a.NoError(err)
+	case <-time.After(time.Second):
+		t.Fatal("Close not done")
+	}
+	a.Equal(int32(4), atomic.LoadInt32(&finished))
+}
+
+//********************
+func TestGoAfterClose(t *testing.T) {
+	a := assert.New(t)
+
+	wg := NewWaitGroup()
+	doneChan := make(chan error, 2)
+	wg.Close(doneChan)
+	ran := false
+	a.True(errors.Is(wg.Go(func() { ran = true }), ErrClosing))
+	// closing twice is allowed
+	wg.Close(doneChan)
+	<-doneChan
+	<-doneChan
+	a.False(ran)
+}
+
+//********************
+func TestGoDuringClose(t *testing.T) {
+	a := assert.New(t)
+
+	// goroutines starting more goroutines while closing, run with -race
+	wg := NewWaitGroup()
+	var started, rejected int32
+	var spawn func()
+	spawn = func() {
+		atomic.AddInt32(&started, 1)
+		if err := wg.Go(spawn); err != nil {
+			atomic.AddInt32(&rejected, 1)
+		}
+	}
+	for i := 0; i < 8; i++ {
+		a.NoError(wg.Go(spawn))
+	}
+	doneChan := make(chan error, 1)
+	wg.Close(doneChan)
+	select {
+	case err := <-doneChan:
+		a.NoError(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close not done")
+	}
+	// each chain of goroutines ends with one rejected Go
+	a.Equal(int32(8), atomic.LoadInt32(&rejected))
+	a.True(atomic.LoadInt32(&started) >= 8)
+}